@@ -0,0 +1,96 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/0xMishra/makerble/internal/data"
+	"github.com/0xMishra/makerble/internal/validator"
+)
+
+func (app *application) registerAdminHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Name            string `json:"name"`
+		Email           string `json:"email"`
+		Password        string `json:"password"`
+		InvitationToken string `json:"invitation_token"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	allowed, err := app.registrationAllowed(r, "admin", input.Email, input.InvitationToken)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if !allowed {
+		app.notPermittedResponse(w, r)
+		return
+	}
+
+	a := &data.Admin{
+		Name:  input.Name,
+		Email: input.Email,
+	}
+
+	err = a.Password.Set(input.Password)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	if data.ValidateAdmin(v, a); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Admins.Insert(a)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateEmail):
+			v.AddError("email", "a user with this email address exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.models.Tokens.DeleteAllForUser(data.ScopeInvitation, a.Email, "admin")
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	token, err := app.models.Tokens.New(3*24*time.Hour, a.Email, "admin", data.ScopeActivation)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.background(func() {
+		tmplData := map[string]any{
+			"name":            a.Name,
+			"role":            "admin",
+			"activationToken": token.Plaintext,
+		}
+
+		err := app.mailer.Send(a.Email, "user_welcome.tmpl", tmplData)
+		if err != nil {
+			app.logger.Error(err.Error())
+		}
+	})
+
+	err = app.writeJSON(w, http.StatusAccepted, envelope{"admin": a}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,356 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/0xMishra/makerble/internal/data"
+	"github.com/0xMishra/makerble/internal/validator"
+)
+
+func (app *application) createAppointmentHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		PatientID int64     `json:"patient_id"`
+		DoctorID  int64     `json:"doctor_id"`
+		StartsAt  time.Time `json:"starts_at"`
+		EndsAt    time.Time `json:"ends_at"`
+		Reason    string    `json:"reason"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	a := &data.Appointment{
+		PatientID: input.PatientID,
+		DoctorID:  input.DoctorID,
+		StartsAt:  input.StartsAt,
+		EndsAt:    input.EndsAt,
+		Status:    data.AppointmentBooked,
+		Reason:    input.Reason,
+	}
+
+	v := validator.New()
+
+	v.Check(a.StartsAt.After(time.Now()), "starts_at", "must be in the future")
+
+	if data.ValidateAppointment(v, a); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	doctorID, ok, err := app.appointmentScope(app.contextGetUser(r))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if !ok || (doctorID != 0 && a.DoctorID != doctorID) {
+		app.notPermittedResponse(w, r)
+		return
+	}
+
+	_, err = app.models.Patients.GetByID(a.PatientID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("patient_id", "patient does not exist")
+			app.failedValidationResponse(w, r, v.Errors)
+
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if !app.checkAppointmentSlot(w, r, a) {
+		return
+	}
+
+	err = app.models.Appointments.Insert(a)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrAppointmentOverlap):
+			app.appointmentConflictResponse(w, r)
+
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/appointments/%d", a.ID))
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"appointment": a}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) listAppointmentsHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		data.AppointmentFilters
+		data.Filters
+	}
+
+	v := validator.New()
+
+	qs := r.URL.Query()
+
+	input.DoctorID = int64(app.readInt(qs, "doctor_id", 0, v))
+	input.PatientID = int64(app.readInt(qs, "patient_id", 0, v))
+	input.Status = app.readString(qs, "status", "")
+	input.From = app.readDate(qs, "from", time.Time{}, v)
+	input.To = app.readDate(qs, "to", time.Time{}, v)
+
+	// to is inclusive, so the range runs until the end of that day.
+	if !input.To.IsZero() {
+		input.To = input.To.AddDate(0, 0, 1)
+	}
+
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+
+	input.Filters.Sort = app.readString(qs, "sort", "starts_at")
+	input.Filters.SortSafelist = []string{"id", "starts_at", "created_at", "-id", "-starts_at", "-created_at"}
+
+	if input.Status != "" {
+		v.Check(validator.PermittedValue(input.Status, data.AppointmentBooked, data.AppointmentCancelled, data.AppointmentCompleted, data.AppointmentNoShow), "status", "must be booked, cancelled, completed or no_show")
+	}
+
+	doctorID, ok, err := app.appointmentScope(app.contextGetUser(r))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if !ok {
+		app.notPermittedResponse(w, r)
+		return
+	}
+
+	if doctorID != 0 {
+		input.DoctorID = doctorID
+	}
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	appointments, metadata, err := app.models.Appointments.GetAll(input.AppointmentFilters, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"appointments": appointments, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) getAppointmentHandler(w http.ResponseWriter, r *http.Request) {
+	a, ok := app.readAppointment(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.writeJSON(w, http.StatusOK, envelope{"appointment": a}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// updateAppointmentHandler reschedules a booked appointment or moves it to
+// one of the final cancelled, completed or no_show statuses.
+func (app *application) updateAppointmentHandler(w http.ResponseWriter, r *http.Request) {
+	a, ok := app.readAppointment(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		StartsAt *time.Time `json:"starts_at"`
+		EndsAt   *time.Time `json:"ends_at"`
+		Status   *string    `json:"status"`
+		Reason   *string    `json:"reason"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	v.Check(a.Status == data.AppointmentBooked, "status", fmt.Sprintf("a %s appointment can no longer be changed", a.Status))
+
+	rescheduled := input.StartsAt != nil || input.EndsAt != nil
+
+	if input.StartsAt != nil {
+		a.StartsAt = *input.StartsAt
+	}
+	if input.EndsAt != nil {
+		a.EndsAt = *input.EndsAt
+	}
+	if input.Status != nil {
+		a.Status = *input.Status
+	}
+	if input.Reason != nil {
+		a.Reason = *input.Reason
+	}
+
+	if rescheduled {
+		v.Check(a.Status == data.AppointmentBooked, "status", "must stay booked when the appointment is rescheduled")
+		v.Check(a.StartsAt.After(time.Now()), "starts_at", "must be in the future")
+	}
+
+	if a.Status == data.AppointmentCompleted || a.Status == data.AppointmentNoShow {
+		v.Check(a.StartsAt.Before(time.Now()), "status", "appointment has not started yet")
+	}
+
+	if data.ValidateAppointment(v, a); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if rescheduled && !app.checkAppointmentSlot(w, r, a) {
+		return
+	}
+
+	err = app.models.Appointments.Update(a)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrAppointmentOverlap):
+			app.appointmentConflictResponse(w, r)
+
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"appointment": a}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// checkAppointmentSlot makes sure the doctor exists, is on shift for the whole
+// appointment, isn't on leave or a holiday and has no other booking at that
+// time. It writes the error response itself and reports false when the
+// handler should stop.
+func (app *application) checkAppointmentSlot(w http.ResponseWriter, r *http.Request, a *data.Appointment) bool {
+	v := validator.New()
+
+	d, err := app.models.Doctors.GetByID(a.DoctorID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("doctor_id", "doctor does not exist")
+			app.failedValidationResponse(w, r, v.Errors)
+
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return false
+	}
+
+	if !d.OnShift(a.StartsAt, a.EndsAt) {
+		v.AddError("starts_at", "appointment must fall within the doctor's shift")
+		app.failedValidationResponse(w, r, v.Errors)
+		return false
+	}
+
+	exceptions, err := app.models.Exceptions.GetApproved("doctor", []int64{d.ID}, a.StartsAt, a.EndsAt)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return false
+	}
+
+	for _, e := range exceptions {
+		if e.Blocks() {
+			v.AddError("starts_at", fmt.Sprintf("doctor is away (%s) at that time", e.Kind))
+			app.failedValidationResponse(w, r, v.Errors)
+			return false
+		}
+	}
+
+	overlap, err := app.models.Appointments.HasOverlap(a.DoctorID, a.StartsAt, a.EndsAt, a.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return false
+	}
+
+	if overlap {
+		app.appointmentConflictResponse(w, r)
+		return false
+	}
+
+	return true
+}
+
+// readAppointment loads the appointment named by the :id URL parameter and
+// checks that it is within the authenticated user's appointmentScope.
+func (app *application) readAppointment(w http.ResponseWriter, r *http.Request) (*data.Appointment, bool) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	a, err := app.models.Appointments.GetByID(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+
+	doctorID, ok, err := app.appointmentScope(app.contextGetUser(r))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return nil, false
+	}
+
+	if !ok || (doctorID != 0 && a.DoctorID != doctorID) {
+		app.notPermittedResponse(w, r)
+		return nil, false
+	}
+
+	return a, true
+}
+
+// appointmentScope returns the doctor whose appointments user is limited to,
+// or 0 when their role holds appointments:all. Roles holding appointments:own
+// instead only see the user's own appointments, and a role with neither sees
+// none; ok is false then.
+func (app *application) appointmentScope(user *data.User) (doctorID int64, ok bool, err error) {
+	permissions, err := app.models.Permissions.GetAllForRole(user.Role)
+	if err != nil {
+		return 0, false, err
+	}
+
+	switch {
+	case permissions.Include("appointments:all"):
+		return 0, true, nil
+	case permissions.Include("appointments:own"):
+		return user.ID, true, nil
+	default:
+		return 0, false, nil
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/0xMishra/makerble/internal/data"
+	"github.com/0xMishra/makerble/internal/validator"
+)
+
+func (app *application) listPatientAuditHandler(w http.ResponseWriter, r *http.Request) {
+	// The trail outlives the patient, so a deleted record can still be
+	// looked up by ID.
+	id, err := app.readIDParam(r)
+	if err != nil || id < 1 {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	var filters data.Filters
+
+	v := validator.New()
+
+	qs := r.URL.Query()
+
+	filters.Page = app.readInt(qs, "page", 1, v)
+	filters.PageSize = app.readInt(qs, "page_size", 20, v)
+
+	filters.Sort = app.readString(qs, "sort", "-id")
+	filters.SortSafelist = []string{"id", "-id"}
+
+	if data.ValidateFilters(v, filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	events, metadata, err := app.models.AuditEvents.GetAllForPatient(id, filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"audit_events": events, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// audit appends an event for each patient to the audit trail on behalf of the
+// authenticated user. It is meant for reads; changes are recorded together
+// with the change itself, see auditEvent.
+func (app *application) audit(r *http.Request, action string, patientIDs ...int64) error {
+	if len(patientIDs) == 0 {
+		return nil
+	}
+
+	events := make([]*data.AuditEvent, 0, len(patientIDs))
+
+	for _, id := range patientIDs {
+		event := app.auditEvent(r, action, nil)
+		event.PatientID = id
+
+		events = append(events, event)
+	}
+
+	return app.models.AuditEvents.Insert(events...)
+}
+
+// auditEvent describes an action by the authenticated user, for a model to
+// append to the audit trail in the same transaction as the change. The model
+// fills in the patient, and the changes where they depend on the database.
+func (app *application) auditEvent(r *http.Request, action string, changes json.RawMessage) *data.AuditEvent {
+	user := app.contextGetUser(r)
+
+	return &data.AuditEvent{
+		ActorEmail: user.Email,
+		ActorRole:  user.Role,
+		Action:     action,
+		RequestID:  app.contextGetRequestID(r),
+		IP:         clientIP(r),
+		Changes:    changes,
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/0xMishra/makerble/internal/data"
+	"github.com/0xMishra/makerble/internal/validator"
+)
+
+func (app *application) doctorAvailabilityHandler(w http.ResponseWriter, r *http.Request) {
+	d, ok := app.readDoctor(w, r)
+	if !ok {
+		return
+	}
+
+	v := validator.New()
+
+	from, to, duration := app.readAvailabilityWindow(r.URL.Query(), v)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	slots, err := app.freeSlots([]*data.Doctor{d}, from, to, duration)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"doctor_id": d.ID, "slots": slots[d.ID]}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) searchAvailabilityHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Specialization string
+		data.Filters
+	}
+
+	v := validator.New()
+
+	qs := r.URL.Query()
+
+	input.Specialization = app.readString(qs, "specialization", "")
+
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 10, v)
+
+	input.Filters.Sort = "id"
+	input.Filters.SortSafelist = []string{"id"}
+
+	v.Check(input.Specialization != "", "specialization", "must be provided")
+
+	from, to, duration := app.readAvailabilityWindow(qs, v)
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	doctors, metadata, err := app.models.Doctors.GetAll("", input.Specialization, true, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	slots, err := app.freeSlots(doctors, from, to, duration)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	type doctorAvailability struct {
+		Doctor formattedDoctor `json:"doctor"`
+		Slots  []data.Slot     `json:"slots"`
+	}
+
+	availability := []doctorAvailability{}
+
+	for _, d := range doctors {
+		availability = append(availability, doctorAvailability{Doctor: formatDoctor(d), Slots: slots[d.ID]})
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"availability": availability, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// readAvailabilityWindow reads the from and to dates (both inclusive, in the
+// server's local time zone) and the slot duration in minutes. The window never
+// starts in the past.
+func (app *application) readAvailabilityWindow(qs url.Values, v *validator.Validator) (time.Time, time.Time, time.Duration) {
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+
+	from := localDate(app.readDate(qs, "from", today, v))
+	to := localDate(app.readDate(qs, "to", from.AddDate(0, 0, 6), v)).AddDate(0, 0, 1)
+	minutes := app.readInt(qs, "duration", 30, v)
+
+	v.Check(to.After(from), "to", "must not be before from")
+	v.Check(to.Sub(from) <= 31*24*time.Hour, "to", "must be at most 31 days after from")
+	v.Check(minutes >= 5, "duration", "must be at least 5 minutes")
+	v.Check(minutes <= 480, "duration", "must be at most 480 minutes")
+
+	if earliest := now.Truncate(5 * time.Minute).Add(5 * time.Minute); from.Before(earliest) {
+		from = earliest
+	}
+
+	return from, to, time.Duration(minutes) * time.Minute
+}
+
+// freeSlots computes the free slots of every doctor, keyed by doctor ID.
+func (app *application) freeSlots(doctors []*data.Doctor, from, to time.Time, duration time.Duration) (map[int64][]data.Slot, error) {
+	slots := make(map[int64][]data.Slot, len(doctors))
+
+	if len(doctors) == 0 {
+		return slots, nil
+	}
+
+	ids := make([]int64, 0, len(doctors))
+	for _, d := range doctors {
+		ids = append(ids, d.ID)
+	}
+
+	appointments, err := app.models.Appointments.GetBookedForDoctors(ids, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	booked := make(map[int64][]*data.Appointment)
+	for _, a := range appointments {
+		booked[a.DoctorID] = append(booked[a.DoctorID], a)
+	}
+
+	approved, err := app.models.Exceptions.GetApproved("doctor", ids, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	// Holidays have no staff ID and are handed to every doctor.
+	exceptions := make(map[int64][]*data.ScheduleException)
+	for _, e := range approved {
+		if e.Kind == data.ExceptionHoliday {
+			for _, id := range ids {
+				exceptions[id] = append(exceptions[id], e)
+			}
+			continue
+		}
+
+		exceptions[e.StaffID] = append(exceptions[e.StaffID], e)
+	}
+
+	for _, d := range doctors {
+		breaks, err := app.models.DoctorBreaks.GetAllForDoctor(d.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		slots[d.ID] = data.FreeSlots(d, from, to, duration, breaks, exceptions[d.ID], booked[d.ID])
+	}
+
+	return slots, nil
+}
+
+func localDate(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/0xMishra/makerble/internal/data"
+)
+
+type contextKey string
+
+const (
+	userContextKey      = contextKey("user")
+	requestIDContextKey = contextKey("request_id")
+	sessionContextKey   = contextKey("session")
+)
+
+func (app *application) contextSetUser(r *http.Request, user *data.User) *http.Request {
+	ctx := context.WithValue(r.Context(), userContextKey, user)
+	return r.WithContext(ctx)
+}
+
+// contextGetUser is only called from handlers wrapped by authenticate, so a
+// missing user is a programming error.
+func (app *application) contextGetUser(r *http.Request) *data.User {
+	user, ok := r.Context().Value(userContextKey).(*data.User)
+	if !ok {
+		panic("missing user value in request context")
+	}
+
+	return user
+}
+
+func (app *application) contextSetRequestID(r *http.Request, id string) *http.Request {
+	ctx := context.WithValue(r.Context(), requestIDContextKey, id)
+	return r.WithContext(ctx)
+}
+
+// contextGetRequestID returns an empty string for requests that didn't pass
+// through the requestID middleware.
+func (app *application) contextGetRequestID(r *http.Request) string {
+	id, _ := r.Context().Value(requestIDContextKey).(string)
+	return id
+}
+
+func (app *application) contextSetSession(r *http.Request, family string) *http.Request {
+	ctx := context.WithValue(r.Context(), sessionContextKey, family)
+	return r.WithContext(ctx)
+}
+
+// contextGetSession returns the token family of the session that
+// authenticated the request, or an empty string for anonymous requests.
+func (app *application) contextGetSession(r *http.Request) string {
+	family, _ := r.Context().Value(sessionContextKey).(string)
+	return family
+}
@@ -9,15 +9,44 @@ import (
 	"github.com/0xMishra/makerble/internal/validator"
 )
 
+type formattedDoctor struct {
+	ID             int64
+	CreatedAt      time.Time
+	Name           string
+	Email          string
+	Specialization string
+	Contact        int64
+	Shifts         data.Schedule
+	Activated      bool
+}
+
+func formatDoctor(d *data.Doctor) formattedDoctor {
+	f := formattedDoctor{
+		ID:             d.ID,
+		CreatedAt:      d.CreatedAt,
+		Name:           d.Name,
+		Email:          d.Email,
+		Specialization: d.Specialization,
+		Contact:        d.Contact,
+		Activated:      d.Activated,
+	}
+
+	f.Shifts = d.Shifts
+
+	return f
+}
+
 func (app *application) registerDoctorHandler(w http.ResponseWriter, r *http.Request) {
 	var input struct {
-		Name           string `json:"name"`
-		Email          string `json:"email"`
-		Password       string `json:"password"`
-		Specialization string `json:"specialization"`
-		Contact        int64  `json:"contact"`
-		ShiftStart     string `json:"shift_start"`
-		ShiftEnd       string `json:"shift_end"`
+		Name            string        `json:"name"`
+		Email           string        `json:"email"`
+		Password        string        `json:"password"`
+		Specialization  string        `json:"specialization"`
+		Contact         int64         `json:"contact"`
+		Shifts          data.Schedule `json:"shifts"`
+		ShiftStart      *string       `json:"shift_start"`
+		ShiftEnd        *string       `json:"shift_end"`
+		InvitationToken string        `json:"invitation_token"`
 	}
 
 	err := app.readJSON(w, r, &input)
@@ -26,25 +55,25 @@ func (app *application) registerDoctorHandler(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	sStart, err := app.parseShiftTiming(input.ShiftStart)
+	allowed, err := app.registrationAllowed(r, "doctor", input.Email, input.InvitationToken)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
 	}
 
-	sEnd, err := app.parseShiftTiming(input.ShiftEnd)
-	if err != nil {
-		app.serverErrorResponse(w, r, err)
+	if !allowed {
+		app.notPermittedResponse(w, r)
 		return
 	}
 
+	v := validator.New()
+
 	d := &data.Doctor{
 		Name:           input.Name,
 		Email:          input.Email,
 		Specialization: input.Specialization,
 		Contact:        input.Contact,
-		ShiftStart:     sStart,
-		ShiftEnd:       sEnd,
+		Shifts:         app.readShifts(v, input.Shifts, input.ShiftStart, input.ShiftEnd),
 	}
 
 	err = d.Password.Set(input.Password)
@@ -53,8 +82,6 @@ func (app *application) registerDoctorHandler(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	v := validator.New()
-
 	if data.ValidateDoctor(v, d); !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
@@ -73,37 +100,256 @@ func (app *application) registerDoctorHandler(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	token, err := app.models.Tokens.New(3*24*time.Hour, d.Email, "doctor", data.ScopeAuthentication)
+	err = app.models.Tokens.DeleteAllForUser(data.ScopeInvitation, d.Email, "doctor")
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	token, err := app.models.Tokens.New(3*24*time.Hour, d.Email, "doctor", data.ScopeActivation)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
 	}
 
-	type formattedDoctor struct {
-		ID             int64
-		CreatedAt      time.Time
+	app.background(func() {
+		tmplData := map[string]any{
+			"name":            d.Name,
+			"role":            "doctor",
+			"activationToken": token.Plaintext,
+		}
+
+		err := app.mailer.Send(d.Email, "user_welcome.tmpl", tmplData)
+		if err != nil {
+			app.logger.Error(err.Error())
+		}
+	})
+
+	err = app.writeJSON(w, http.StatusAccepted, envelope{"doctor": formatDoctor(d)}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) listDoctorsHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
 		Name           string
-		Email          string
 		Specialization string
-		Contact        int64
-		ShiftStart     string
-		ShiftEnd       string
+		data.Filters
 	}
 
-	f := formattedDoctor{
-		ID:             d.ID,
-		CreatedAt:      d.CreatedAt,
-		Name:           d.Name,
-		Email:          d.Email,
-		Specialization: d.Specialization,
-		Contact:        d.Contact,
+	v := validator.New()
+
+	qs := r.URL.Query()
+
+	input.Name = app.readString(qs, "name", "")
+	input.Specialization = app.readString(qs, "specialization", "")
+
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+
+	input.Filters.Sort = app.readString(qs, "sort", "id")
+	input.Filters.SortSafelist = []string{"id", "name", "specialization", "created_at", "-id", "-name", "-specialization", "-created_at"}
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	doctors, metadata, err := app.models.Doctors.GetAll(input.Name, input.Specialization, false, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
 	}
 
-	f.ShiftStart = d.ShiftStart.Format("3:04 PM")
-	f.ShiftEnd = d.ShiftEnd.Format("3:04 PM")
+	formatted := make([]formattedDoctor, 0, len(doctors))
+	for _, d := range doctors {
+		formatted = append(formatted, formatDoctor(d))
+	}
 
-	err = app.writeJSON(w, http.StatusCreated, envelope{"doctor": f, "token": token}, nil)
+	err = app.writeJSON(w, http.StatusOK, envelope{"doctors": formatted, "metadata": metadata}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
 }
+
+func (app *application) getDoctorHandler(w http.ResponseWriter, r *http.Request) {
+	d, ok := app.readDoctor(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.writeJSON(w, http.StatusOK, envelope{"doctor": formatDoctor(d)}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) updateDoctorHandler(w http.ResponseWriter, r *http.Request) {
+	d, ok := app.readDoctor(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Name           *string       `json:"name"`
+		Email          *string       `json:"email"`
+		Specialization *string       `json:"specialization"`
+		Contact        *int64        `json:"contact"`
+		Shifts         data.Schedule `json:"shifts"`
+		ShiftStart     *string       `json:"shift_start"`
+		ShiftEnd       *string       `json:"shift_end"`
+		Activated      *bool         `json:"activated"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	previousEmail := d.Email
+
+	if input.Name != nil {
+		d.Name = *input.Name
+	}
+	if input.Email != nil {
+		d.Email = *input.Email
+	}
+	if input.Specialization != nil {
+		d.Specialization = *input.Specialization
+	}
+	if input.Contact != nil {
+		d.Contact = *input.Contact
+	}
+	if input.Activated != nil {
+		d.Activated = *input.Activated
+	}
+
+	v := validator.New()
+
+	if shifts := app.readShifts(v, input.Shifts, input.ShiftStart, input.ShiftEnd); shifts != nil {
+		d.Shifts = shifts
+	}
+
+	if data.ValidateDoctor(v, d); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Doctors.Update(d)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateEmail):
+			v.AddError("email", "email already registered")
+			app.failedValidationResponse(w, r, v.Errors)
+
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// Tokens are tied to an email address, so a deactivated account or a
+	// changed address must not keep its existing sessions.
+	if !d.Activated || d.Email != previousEmail {
+		err = app.models.Tokens.RevokeAllForUser(previousEmail, "doctor")
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	if d.Email != previousEmail {
+		err = app.models.TwoFactor.ChangeEmail("doctor", previousEmail, d.Email)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"doctor": formatDoctor(d)}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) resetDoctorPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	d, ok := app.readDoctor(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Password string `json:"password"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	err = d.Password.Set(input.Password)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	if data.ValidateDoctor(v, d); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Doctors.Update(d)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.models.Tokens.RevokeAllForUser(d.Email, "doctor")
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "password reset successfully"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// readDoctor loads the doctor named by the :id URL parameter and writes the
+// error response itself when that fails.
+func (app *application) readDoctor(w http.ResponseWriter, r *http.Request) (*data.Doctor, bool) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	d, err := app.models.Doctors.GetByID(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+
+	return d, true
+}
@@ -1,7 +1,12 @@
 package main
 
 import (
+	"errors"
 	"net/http"
+	"strings"
+	"time"
+
+	"github.com/0xMishra/makerble/internal/data"
 )
 
 func (app *application) registerHandler(w http.ResponseWriter, r *http.Request) {
@@ -14,7 +19,45 @@ func (app *application) registerHandler(w http.ResponseWriter, r *http.Request)
 	case "receptionist":
 		app.registerReceptionistHandler(w, r)
 
+	case "admin":
+		app.registerAdminHandler(w, r)
+
 	default:
 		app.badRequestResponse(w, r, http.ErrAbortHandler)
 	}
 }
+
+// registrationAllowed reports whether the request may create an account with
+// the given role and email. Activated users holding the users:write
+// permission may register anyone, everybody else needs an unexpired
+// invitation token issued for that email and role.
+func (app *application) registrationAllowed(r *http.Request, role, email, invitationToken string) (bool, error) {
+	user := app.contextGetUser(r)
+
+	if !user.IsAnonymous() && user.Activated {
+		permissions, err := app.models.Permissions.GetAllForRole(user.Role)
+		if err != nil {
+			return false, err
+		}
+
+		if permissions.Include("users:write") {
+			return true, nil
+		}
+	}
+
+	if invitationToken == "" {
+		return false, nil
+	}
+
+	t, err := app.models.Tokens.GetUserForToken(data.ScopeInvitation, invitationToken)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return false, nil
+		default:
+			return false, err
+		}
+	}
+
+	return t.Role == role && strings.EqualFold(t.Email, email) && time.Now().Before(t.Expiry), nil
+}
@@ -6,12 +6,14 @@ import (
 	"fmt"
 	"io"
 	"maps"
+	"net"
 	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/0xMishra/makerble/internal/data"
 	"github.com/0xMishra/makerble/internal/validator"
 	"github.com/julienschmidt/httprouter"
 )
@@ -29,6 +31,17 @@ func (app *application) readIDParam(r *http.Request) (int64, error) {
 	return id, nil
 }
 
+func (app *application) readInt64Param(r *http.Request, name string) (int64, error) {
+	params := httprouter.ParamsFromContext(r.Context())
+	i, err := strconv.ParseInt(params.ByName(name), 10, 64)
+
+	if err != nil || i < 1 {
+		return 0, fmt.Errorf("invalid %s parameter", name)
+	}
+
+	return i, nil
+}
+
 func (app *application) writeJSON(w http.ResponseWriter, status int, data envelope, headers http.Header) error {
 	js, err := json.MarshalIndent(data, "", "\t")
 	if err != nil {
@@ -132,6 +145,36 @@ func (app *application) readInt(qs url.Values, key string, defaultValue int, v *
 	return i
 }
 
+func (app *application) readBool(qs url.Values, key string, defaultValue bool, v *validator.Validator) bool {
+	s := qs.Get(key)
+	if s == "" {
+		return defaultValue
+	}
+
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		v.AddError(key, "must be a boolean value")
+		return defaultValue
+	}
+
+	return b
+}
+
+func (app *application) readDate(qs url.Values, key string, defaultValue time.Time, v *validator.Validator) time.Time {
+	s := qs.Get(key)
+	if s == "" {
+		return defaultValue
+	}
+
+	t, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		v.AddError(key, "must be a date in the YYYY-MM-DD format")
+		return defaultValue
+	}
+
+	return t
+}
+
 func (app *application) background(fn func()) {
 	// Launch a background goroutine.
 	app.wg.Add(1)
@@ -148,12 +191,100 @@ func (app *application) background(fn func()) {
 	}()
 }
 
-func (app *application) parseShiftTiming(shift string) (time.Time, error) {
-	t, err := time.Parse("15:04", shift)
+// readShifts picks the schedule sent with a staff member. A "shifts" list
+// wins; otherwise the older shift_start and shift_end pair is read as the
+// same hours on every day of the week. It returns nil when neither was sent.
+func (app *application) readShifts(v *validator.Validator, shifts data.Schedule, shiftStart, shiftEnd *string) data.Schedule {
+	if shifts != nil {
+		return shifts
+	}
+
+	if shiftStart == nil && shiftEnd == nil {
+		return nil
+	}
+
+	v.Check(shiftStart != nil, "shift_start", "must be provided")
+	v.Check(shiftEnd != nil, "shift_end", "must be provided")
+	if !v.Valid() {
+		return nil
+	}
+
+	start, err := data.ParseClockTime(*shiftStart)
+	if err != nil {
+		v.AddError("shift_start", err.Error())
+	}
+
+	end, err := data.ParseClockTime(*shiftEnd)
+	if err != nil {
+		v.AddError("shift_end", err.Error())
+	}
+
+	if !v.Valid() {
+		return nil
+	}
+
+	return data.DailySchedule(start, end)
+}
+
+// patientETag identifies a version of a patient record. The version is
+// bumped on every change, so it is all a client needs to send back.
+func patientETag(p *data.Patient) string {
+	return fmt.Sprintf(`"%d"`, p.Version)
+}
+
+// etagMatches reports whether etag is listed in an If-Match or If-None-Match
+// header value. Weak tags only match when weak is set, as If-Match requires a
+// strong comparison.
+func etagMatches(header, etag string, weak bool) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+
+		if tag == "*" {
+			return true
+		}
+
+		if strings.HasPrefix(tag, "W/") {
+			if !weak {
+				continue
+			}
+			tag = strings.TrimPrefix(tag, "W/")
+		}
+
+		if tag == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkIfMatch compares the request's If-Match header with the current ETag
+// of the record and sends a 412 Precondition Failed when they differ. A
+// missing header is let through unless -require-if-match is set.
+func (app *application) checkIfMatch(w http.ResponseWriter, r *http.Request, etag string) bool {
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch == "" {
+		if app.config.concurrency.requireIfMatch {
+			app.preconditionRequiredResponse(w, r)
+			return false
+		}
+		return true
+	}
+
+	if !etagMatches(ifMatch, etag, false) {
+		app.preconditionFailedResponse(w, r)
+		return false
+	}
+
+	return true
+}
+
+// clientIP returns the address of the client without the port.
+func clientIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
 	if err != nil {
-		fmt.Println("Error parsing time:", err)
-		return time.Time{}, err
+		return r.RemoteAddr
 	}
 
-	return t, nil
+	return ip
 }
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/0xMishra/makerble/internal/data"
+	"github.com/0xMishra/makerble/internal/validator"
+)
+
+func (app *application) createInvitationHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Email string `json:"email"`
+		Role  string `json:"role"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	validator.ValidateEmail(v, input.Email)
+	v.Check(validator.PermittedValue(input.Role, "doctor", "receptionist", "admin"), "role", "must be doctor, receptionist or admin")
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	token, err := app.models.Tokens.New(7*24*time.Hour, input.Email, input.Role, data.ScopeInvitation)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.background(func() {
+		tmplData := map[string]any{
+			"role":            input.Role,
+			"invitationToken": token.Plaintext,
+		}
+
+		err := app.mailer.Send(input.Email, "user_invitation.tmpl", tmplData)
+		if err != nil {
+			app.logger.Error(err.Error())
+		}
+	})
+
+	err = app.writeJSON(w, http.StatusAccepted, envelope{"message": "an email will be sent to you containing the invitation"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -13,6 +13,7 @@ import (
 	"time"
 
 	"github.com/0xMishra/makerble/internal/data"
+	"github.com/0xMishra/makerble/internal/mailer"
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
 )
@@ -45,6 +46,39 @@ type config struct {
 		sender   string
 	}
 
+	mailer struct {
+		transport string
+		dir       string
+		retries   int
+		backoff   time.Duration
+	}
+
+	shifts struct {
+		enforce bool
+		grace   time.Duration
+	}
+
+	concurrency struct {
+		requireIfMatch bool
+	}
+
+	idempotency struct {
+		ttl time.Duration
+	}
+
+	tokens struct {
+		accessTTL     time.Duration
+		refreshTTL    time.Duration
+		sweepInterval time.Duration
+	}
+
+	login struct {
+		maxFailures   int
+		ipMaxFailures int
+		delay         time.Duration
+		lockout       time.Duration
+	}
+
 	cors struct {
 		trustedOrigins []string
 	}
@@ -55,6 +89,7 @@ type application struct {
 	config config
 	logger *slog.Logger
 	models data.Models
+	mailer mailer.Mailer
 	wg     sync.WaitGroup
 }
 
@@ -80,6 +115,34 @@ func main() {
 	flag.IntVar(&cfg.limiter.burst, "limiter-burst", 4, "Rate limiter maximum burst")
 	flag.BoolVar(&cfg.limiter.enabled, "limiter-enabled", true, "Enable rate limiter")
 
+	// The defaults point at a local SMTP stand-in such as MailHog or Mailpit.
+	flag.StringVar(&cfg.smtp.host, "smtp-host", "localhost", "SMTP host")
+	flag.IntVar(&cfg.smtp.port, "smtp-port", 1025, "SMTP port")
+	flag.StringVar(&cfg.smtp.username, "smtp-username", "", "SMTP username")
+	flag.StringVar(&cfg.smtp.password, "smtp-password", "", "SMTP password")
+	flag.StringVar(&cfg.smtp.sender, "smtp-sender", "Makerble <no-reply@makerble.local>", "SMTP sender")
+
+	flag.StringVar(&cfg.mailer.transport, "mailer-transport", "smtp", "Mail transport (smtp|file|memory)")
+	flag.StringVar(&cfg.mailer.dir, "mailer-dir", "./tmp/mail", "Directory the file mail transport writes to")
+	flag.IntVar(&cfg.mailer.retries, "mailer-retries", 3, "Number of times a failed email is retried")
+	flag.DurationVar(&cfg.mailer.backoff, "mailer-backoff", 500*time.Millisecond, "Wait before the first email retry, doubled on every further retry")
+
+	flag.BoolVar(&cfg.shifts.enforce, "shift-enforce", false, "Only let receptionists change patients during their shift")
+	flag.DurationVar(&cfg.shifts.grace, "shift-grace", 15*time.Minute, "Time before and after a shift in which receptionists may still make changes")
+
+	flag.BoolVar(&cfg.concurrency.requireIfMatch, "require-if-match", false, "Reject patient changes that don't send an If-Match header")
+
+	flag.DurationVar(&cfg.idempotency.ttl, "idempotency-ttl", 24*time.Hour, "How long responses are kept for replay under their Idempotency-Key")
+
+	flag.DurationVar(&cfg.tokens.accessTTL, "access-token-ttl", 15*time.Minute, "Lifetime of authentication tokens")
+	flag.DurationVar(&cfg.tokens.refreshTTL, "refresh-token-ttl", 30*24*time.Hour, "Lifetime of refresh tokens")
+	flag.DurationVar(&cfg.tokens.sweepInterval, "token-sweep-interval", time.Hour, "How often expired tokens are removed (0 to disable)")
+
+	flag.IntVar(&cfg.login.maxFailures, "login-max-failures", 5, "Failed logins after which an email address is locked out")
+	flag.IntVar(&cfg.login.ipMaxFailures, "login-ip-max-failures", 50, "Failed logins after which a client IP is locked out")
+	flag.DurationVar(&cfg.login.delay, "login-delay", time.Second, "Wait after the first failed login, doubled on every further failure")
+	flag.DurationVar(&cfg.login.lockout, "login-lockout", 15*time.Minute, "How long a lockout lasts, and how long failed logins are remembered")
+
 	flag.Func("cors-trusted-origins", "Trusted CORS origins (space separated)", func(val string) error {
 		cfg.cors.trustedOrigins = strings.Fields(val)
 		return nil
@@ -105,10 +168,17 @@ func main() {
 
 	logger.Info("database connection pool established")
 
+	m, err := newMailer(cfg)
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+
 	app := &application{
 		config: cfg,
 		logger: logger,
 		models: data.NewModels(db),
+		mailer: m,
 	}
 
 	err = app.serve()
@@ -137,3 +207,23 @@ func openDB(cfg config) (*sql.DB, error) {
 
 	return db, nil
 }
+
+func newMailer(cfg config) (mailer.Mailer, error) {
+	var transport mailer.Transport
+
+	switch cfg.mailer.transport {
+	case "smtp":
+		transport = mailer.NewSMTPTransport(cfg.smtp.host, cfg.smtp.port, cfg.smtp.username, cfg.smtp.password)
+
+	case "file":
+		transport = mailer.NewFileTransport(cfg.mailer.dir)
+
+	case "memory":
+		transport = mailer.NewMemoryTransport()
+
+	default:
+		return mailer.Mailer{}, fmt.Errorf("unknown mailer transport %q", cfg.mailer.transport)
+	}
+
+	return mailer.New(transport, cfg.smtp.sender, cfg.mailer.retries, cfg.mailer.backoff), nil
+}
@@ -1,10 +1,16 @@
 package main
 
 import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
+	"regexp"
 	"strings"
 	"sync"
 	"time"
@@ -27,6 +33,27 @@ func (app *application) recoverPanic(next http.Handler) http.Handler {
 	})
 }
 
+var requestIDRX = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// requestID tags every request with an ID that is echoed back in the
+// X-Request-ID header. A well-formed ID sent by the client or a proxy is kept,
+// otherwise a random one is generated.
+func (app *application) requestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+
+		if !validator.Matches(id, requestIDRX) {
+			b := make([]byte, 16)
+			rand.Read(b)
+			id = hex.EncodeToString(b)
+		}
+
+		w.Header().Set("X-Request-ID", id)
+
+		next.ServeHTTP(w, app.contextSetRequestID(r, id))
+	})
+}
+
 func (app *application) rateLimit(next http.Handler) http.Handler {
 	type client struct {
 		limiter  *rate.Limiter
@@ -117,10 +144,11 @@ func (app *application) enableCORS(next http.Handler) http.Handler {
 			for i := range app.config.cors.trustedOrigins {
 				if origin == app.config.cors.trustedOrigins[i] {
 					w.Header().Set("Access-Control-Allow-Origin", origin)
+					w.Header().Set("Access-Control-Expose-Headers", "ETag, Idempotent-Replayed, X-Request-ID")
 
 					if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
 						w.Header().Set("Access-Control-Allow-Methods", "OPTIONS, PUT, PATCH, DELETE")
-						w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+						w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type, Idempotency-Key, If-Match, If-None-Match, X-Request-ID")
 						w.WriteHeader(http.StatusOK)
 						return
 					}
@@ -133,27 +161,35 @@ func (app *application) enableCORS(next http.Handler) http.Handler {
 	})
 }
 
-func (app *application) authenticate(role1, role2 string, next http.HandlerFunc) http.HandlerFunc {
+func (app *application) authenticate(next http.Handler) http.Handler {
 	return http.HandlerFunc(
 		func(w http.ResponseWriter, r *http.Request) {
-			bearerToken := r.Header.Get("Authorization")
-			sliceToken := strings.Split(bearerToken, " ")
-			token := sliceToken[1]
+			w.Header().Add("Vary", "Authorization")
 
-			v := validator.New()
+			bearerToken := r.Header.Get("Authorization")
+			if bearerToken == "" {
+				r = app.contextSetUser(r, data.AnonymousUser)
+				next.ServeHTTP(w, r)
+				return
+			}
 
-			if sliceToken[0] != "Bearer" {
+			sliceToken := strings.Split(bearerToken, " ")
+			if len(sliceToken) != 2 || sliceToken[0] != "Bearer" {
 				app.invalidAuthenticationTokenResponse(w, r)
 				return
 			}
 
+			token := sliceToken[1]
+
+			v := validator.New()
+
 			if data.ValidateTokenPlaintext(v, token); !v.Valid() {
 				app.failedValidationResponse(w, r, v.Errors)
 				return
 			}
 
 			var t *data.Token
-			t, err := app.models.Tokens.GetUserForToken(token)
+			t, err := app.models.Tokens.GetUserForToken(data.ScopeAuthentication, token)
 			if err != nil {
 				switch {
 				case errors.Is(err, data.ErrRecordNotFound):
@@ -165,22 +201,296 @@ func (app *application) authenticate(role1, role2 string, next http.HandlerFunc)
 				return
 			}
 
-			if t.Role != role1 && t.Role != role2 {
-				app.invalidCredentialsResponse(w, r)
+			// Expired tokens are left for the sweeper to remove.
+			if time.Now().After(t.Expiry) {
+				app.invalidAuthenticationTokenResponse(w, r)
 				return
 			}
 
-			currentTime := time.Now()
+			user, err := app.getUser(t.Role, t.Email)
+			if err != nil {
+				switch {
+				case errors.Is(err, data.ErrRecordNotFound):
+					app.invalidAuthenticationTokenResponse(w, r)
+
+				default:
+					app.serverErrorResponse(w, r, err)
+				}
+				return
+			}
 
-			if currentTime.After(t.Expiry) {
-				err = app.models.Tokens.DeleteAllForUser(data.ScopeAuthentication, t.Email)
+			if t.Family != "" {
+				err = app.models.Sessions.Touch(t.Family)
 				if err != nil {
 					app.serverErrorResponse(w, r, err)
 					return
 				}
+
+				r = app.contextSetSession(r, t.Family)
 			}
 
+			r = app.contextSetUser(r, user)
+
 			next.ServeHTTP(w, r)
 		},
 	)
 }
+
+func (app *application) requireAuthenticatedUser(next http.HandlerFunc) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := app.contextGetUser(r)
+
+		if user.IsAnonymous() {
+			app.authenticationRequiredResponse(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (app *application) requireActivatedUser(next http.HandlerFunc) http.HandlerFunc {
+	fn := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := app.contextGetUser(r)
+
+		if !user.Activated {
+			app.inactiveAccountResponse(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+
+	return app.requireAuthenticatedUser(fn)
+}
+
+// requirePermission lets the request through only when the role of the
+// authenticated user has been granted the permission code. Users whose role
+// requires two-factor authentication are also turned away until they have
+// enabled it.
+func (app *application) requirePermission(code string, next http.HandlerFunc) http.HandlerFunc {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		allowed, err := app.hasPermission(app.contextGetUser(r), code)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		if !allowed {
+			app.notPermittedResponse(w, r)
+			return
+		}
+
+		enrolled, err := app.twoFactorSatisfied(app.contextGetUser(r))
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		if !enrolled {
+			app.twoFactorRequiredResponse(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	}
+
+	return app.requireActivatedUser(fn)
+}
+
+// requireOnShift stops receptionists from making changes outside their shift,
+// widened by the configured grace period, unless an admin has granted them an
+// override for the current time, in which case the request is recorded
+// against the override. It does nothing unless shift enforcement is enabled.
+func (app *application) requireOnShift(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user := app.contextGetUser(r)
+
+		if !app.config.shifts.enforce || user.Role != "receptionist" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		rec, err := app.models.Receptionists.GetByID(user.ID)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		now := time.Now()
+
+		if !rec.Shifts.OnDutyWithin(now, app.config.shifts.grace) {
+			overrideID, err := app.models.Overrides.Active(rec.ID, now)
+			if err != nil {
+				switch {
+				case errors.Is(err, data.ErrRecordNotFound):
+					app.outsideShiftResponse(w, r)
+				default:
+					app.serverErrorResponse(w, r, err)
+				}
+				return
+			}
+
+			err = app.models.Overrides.RecordUse(overrideID, r.Method, r.URL.RequestURI())
+			if err != nil {
+				app.serverErrorResponse(w, r, err)
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	}
+}
+
+// hasPermission reports whether the role of user has been granted code.
+func (app *application) hasPermission(user *data.User, code string) (bool, error) {
+	permissions, err := app.models.Permissions.GetAllForRole(user.Role)
+	if err != nil {
+		return false, err
+	}
+
+	return permissions.Include(code), nil
+}
+
+// idempotencyKeyHeaders are the response headers kept with an idempotency key
+// and sent again on replay. The rest are set afresh by the other middleware.
+var idempotencyKeyHeaders = []string{"Content-Type", "Location", "ETag"}
+
+// idempotencyRecorder passes the response through while keeping a copy of it.
+type idempotencyRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *idempotencyRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *idempotencyRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	rec.body.Write(b)
+	return rec.ResponseWriter.Write(b)
+}
+
+// idempotent lets clients safely retry a POST by sending an Idempotency-Key
+// header. The first request with a key is handled as usual and its response
+// is stored; later requests with the same key and body get that response
+// back instead of being handled again. Reusing a key for a different request
+// is rejected, and so is a retry that arrives while the first is running.
+// Server errors aren't stored, so the client can retry them with the same key.
+func (app *application) idempotent(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("Idempotency-Key")
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if len(key) > 255 {
+			app.badRequestResponse(w, r, errors.New("the Idempotency-Key header must not be more than 255 bytes long"))
+			return
+		}
+
+		maxBytes := 1_048_576
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, int64(maxBytes)))
+		if err != nil {
+			app.badRequestResponse(w, r, fmt.Errorf("body must not be larger than %d bytes", maxBytes))
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		h := sha256.New()
+		fmt.Fprintf(h, "%s %s\n", r.Method, r.URL.RequestURI())
+		h.Write(body)
+		fingerprint := h.Sum(nil)
+
+		// Anonymous callers are told apart by their IP, so that one can't
+		// replay or block another's key.
+		owner := "anonymous:" + clientIP(r)
+		if user := app.contextGetUser(r); !user.IsAnonymous() {
+			owner = fmt.Sprintf("%s:%d", user.Role, user.ID)
+		}
+
+		existing, reserved, err := app.models.Idempotency.Reserve(owner, key, fingerprint, app.config.idempotency.ttl)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				app.idempotencyKeyInFlightResponse(w, r)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+
+		if !reserved {
+			switch {
+			case !bytes.Equal(existing.Fingerprint, fingerprint):
+				app.idempotencyKeyMismatchResponse(w, r)
+
+			case existing.Response == nil:
+				app.idempotencyKeyInFlightResponse(w, r)
+
+			default:
+				for name, values := range existing.Response.Header {
+					w.Header()[name] = values
+				}
+				w.Header().Set("Idempotent-Replayed", "true")
+				w.WriteHeader(existing.Response.StatusCode)
+				w.Write(existing.Response.Body)
+			}
+			return
+		}
+
+		completed := false
+
+		// Free the key if the handler panics or fails, so it isn't stuck
+		// until it expires.
+		defer func() {
+			if completed {
+				return
+			}
+
+			err := app.models.Idempotency.Release(owner, key)
+			if err != nil {
+				app.logError(r, err)
+			}
+		}()
+
+		rec := &idempotencyRecorder{ResponseWriter: w}
+
+		next.ServeHTTP(rec, r)
+
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+
+		if rec.status >= http.StatusInternalServerError {
+			return
+		}
+
+		header := make(map[string][]string)
+		for _, name := range idempotencyKeyHeaders {
+			if values := w.Header().Values(name); len(values) > 0 {
+				header[name] = values
+			}
+		}
+
+		err = app.models.Idempotency.Complete(owner, key, &data.IdempotentResponse{
+			StatusCode: rec.status,
+			Header:     header,
+			Body:       rec.body.Bytes(),
+		})
+		if err != nil {
+			app.logError(r, err)
+			return
+		}
+
+		completed = true
+	}
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/0xMishra/makerble/internal/data"
+	"github.com/0xMishra/makerble/internal/jsonpatch"
+	"github.com/0xMishra/makerble/internal/validator"
+)
+
+const (
+	mergePatchMediaType = "application/merge-patch+json"
+	jsonPatchMediaType  = "application/json-patch+json"
+)
+
+// patientDocument holds the fields of a patient that a PATCH may change. The
+// patch is applied to this document, so paths name these JSON keys.
+type patientDocument struct {
+	Name           string    `json:"name"`
+	Gender         string    `json:"gender"`
+	Age            float64   `json:"age"`
+	Contact        int64     `json:"contact"`
+	Address        string    `json:"address"`
+	MedicalHistory string    `json:"medical_history"`
+	InsuranceInfo  string    `json:"insurance_info"`
+	LastVisit      time.Time `json:"last_visit"`
+	DoctorID       int64     `json:"doctor_id"`
+}
+
+// patchPatientHandler applies a JSON Merge Patch or a JSON Patch, picked by
+// the Content-Type, to a patient. The patch is applied to a copy and the
+// result validated as a whole, so a request either changes the record as
+// asked or not at all. Removing a member resets it to its zero value.
+func (app *application) patchPatientHandler(w http.ResponseWriter, r *http.Request) {
+	patient, ok := app.readAccessiblePatient(w, r)
+	if !ok {
+		return
+	}
+
+	if !app.checkIfMatch(w, r, patientETag(patient)) {
+		return
+	}
+
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || (mediaType != mergePatchMediaType && mediaType != jsonPatchMediaType) {
+		app.unsupportedMediaTypeResponse(w, r, mergePatchMediaType, jsonPatchMediaType)
+		return
+	}
+
+	maxBytes := 1_048_576
+	r.Body = http.MaxBytesReader(w, r.Body, int64(maxBytes))
+
+	patch, err := io.ReadAll(r.Body)
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+
+		switch {
+		case errors.As(err, &maxBytesError):
+			app.badRequestResponse(w, r, fmt.Errorf("body must not be larger than %d bytes", maxBytesError.Limit))
+		default:
+			app.badRequestResponse(w, r, err)
+		}
+		return
+	}
+
+	doc, err := json.Marshal(patientDocument{
+		Name:           patient.Name,
+		Gender:         patient.Gender,
+		Age:            patient.Age,
+		Contact:        patient.Contact,
+		Address:        patient.Address,
+		MedicalHistory: patient.MedicalHistory,
+		InsuranceInfo:  patient.InsuranceInfo,
+		LastVisit:      patient.LastVisit,
+		DoctorID:       patient.DoctorID,
+	})
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	switch mediaType {
+	case mergePatchMediaType:
+		doc, err = jsonpatch.MergePatch(doc, patch)
+	default:
+		doc, err = jsonpatch.Apply(doc, patch)
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, jsonpatch.ErrMalformed):
+			app.badRequestResponse(w, r, err)
+		case errors.Is(err, jsonpatch.ErrConflict):
+			app.patchConflictResponse(w, r, err)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	var result patientDocument
+
+	err = decodePatchResult(doc, &result)
+	if err != nil {
+		app.unprocessableEntityResponse(w, r, err)
+		return
+	}
+
+	before := *patient
+
+	patient.Name = result.Name
+	patient.Gender = result.Gender
+	patient.Age = result.Age
+	patient.Contact = result.Contact
+	patient.Address = result.Address
+	patient.MedicalHistory = result.MedicalHistory
+	patient.InsuranceInfo = result.InsuranceInfo
+	patient.LastVisit = result.LastVisit
+	patient.DoctorID = result.DoctorID
+
+	v := validator.New()
+
+	data.ValidatePatient(v, patient)
+
+	err = app.checkDoctorChange(v, app.contextGetUser(r), before.DoctorID, patient)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	changes, err := data.PatientChanges(&before, patient)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.models.Patients.Update(patient, app.auditEvent(r, data.AuditUpdate, changes))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("ETag", patientETag(patient))
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"patient": patient}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// decodePatchResult reads the patched document back. A patch that adds a
+// member the document doesn't have, or changes the type of one, is an error.
+func decodePatchResult(doc []byte, dst *patientDocument) error {
+	var fields map[string]json.RawMessage
+
+	err := json.Unmarshal(doc, &fields)
+	if err != nil {
+		return errors.New("patched patient must be a JSON object")
+	}
+
+	for key, value := range fields {
+		// A null left by a JSON Patch counts as removing the member.
+		if string(value) == "null" {
+			delete(fields, key)
+		}
+	}
+
+	doc, err = json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(doc))
+	dec.DisallowUnknownFields()
+
+	err = dec.Decode(dst)
+	if err != nil {
+		var unmarshalTypeError *json.UnmarshalTypeError
+
+		switch {
+		case errors.As(err, &unmarshalTypeError):
+			return fmt.Errorf("patched patient has an incorrect JSON type for field %q", unmarshalTypeError.Field)
+		case strings.HasPrefix(err.Error(), "json: unknown field "):
+			return fmt.Errorf("patched patient contains unknown key %s", strings.TrimPrefix(err.Error(), "json: unknown field "))
+		default:
+			return fmt.Errorf("patched patient is invalid: %w", err)
+		}
+	}
+
+	return nil
+}
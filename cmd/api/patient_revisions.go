@@ -0,0 +1,175 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/0xMishra/makerble/internal/data"
+	"github.com/0xMishra/makerble/internal/validator"
+)
+
+func (app *application) listPatientVersionsHandler(w http.ResponseWriter, r *http.Request) {
+	patient, ok := app.readAccessiblePatient(w, r)
+	if !ok {
+		return
+	}
+
+	var filters data.Filters
+
+	v := validator.New()
+
+	qs := r.URL.Query()
+
+	filters.Page = app.readInt(qs, "page", 1, v)
+	filters.PageSize = app.readInt(qs, "page_size", 20, v)
+
+	filters.Sort = app.readString(qs, "sort", "-version")
+	filters.SortSafelist = []string{"version", "-version"}
+
+	if data.ValidateFilters(v, filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	revisions, metadata, err := app.models.Revisions.GetAllForPatient(patient.ID, filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.audit(r, data.AuditView, patient.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"versions": revisions, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) showPatientVersionHandler(w http.ResponseWriter, r *http.Request) {
+	patient, ok := app.readAccessiblePatient(w, r)
+	if !ok {
+		return
+	}
+
+	revision, ok := app.readPatientRevision(w, r, patient)
+	if !ok {
+		return
+	}
+
+	err := app.audit(r, data.AuditView, patient.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"version": revision}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// revertPatientHandler writes the values of an earlier version back to the
+// patient. The revert is a new version, so the history stays intact.
+func (app *application) revertPatientHandler(w http.ResponseWriter, r *http.Request) {
+	patient, ok := app.readAccessiblePatient(w, r)
+	if !ok {
+		return
+	}
+
+	if !app.checkIfMatch(w, r, patientETag(patient)) {
+		return
+	}
+
+	revision, ok := app.readPatientRevision(w, r, patient)
+	if !ok {
+		return
+	}
+
+	v := validator.New()
+
+	if v.Check(revision.Version != patient.Version, "version", "is already the current version"); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	before := *patient
+
+	patient.Name = revision.Name
+	patient.Gender = revision.Gender
+	patient.Age = revision.Age
+	patient.Contact = revision.Contact
+	patient.Address = revision.Address
+	patient.MedicalHistory = revision.MedicalHistory
+	patient.InsuranceInfo = revision.InsuranceInfo
+	patient.LastVisit = revision.LastVisit
+	patient.DoctorID = revision.DoctorID
+
+	// The old version was valid when it was written, but the rules or the
+	// doctors on record may have changed since.
+	data.ValidatePatient(v, patient)
+
+	err := app.checkDoctorChange(v, app.contextGetUser(r), before.DoctorID, patient)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	changes, err := data.PatientChanges(&before, patient)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.models.Patients.Update(patient, app.auditEvent(r, data.AuditRevert, changes))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("ETag", patientETag(patient))
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"patient": patient}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// readPatientRevision loads the version of patient named by the :version URL
+// parameter.
+func (app *application) readPatientRevision(w http.ResponseWriter, r *http.Request, patient *data.Patient) (*data.PatientRevision, bool) {
+	version, err := app.readInt64Param(r, "version")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	revision, err := app.models.Revisions.Get(patient.ID, version)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+
+	return revision, true
+}
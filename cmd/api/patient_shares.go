@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/0xMishra/makerble/internal/data"
+	"github.com/0xMishra/makerble/internal/validator"
+)
+
+func (app *application) sharePatientHandler(w http.ResponseWriter, r *http.Request) {
+	patient, ok := app.readSharablePatient(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		DoctorID int64 `json:"doctor_id"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	v.Check(input.DoctorID > 0, "doctor_id", "must be provided")
+	v.Check(input.DoctorID != patient.DoctorID, "doctor_id", "patient is already assigned to this doctor")
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.PatientShares.Insert(patient.ID, input.DoctorID, app.auditEvent(r, data.AuditShare, shareChange(nil, &input.DoctorID)))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("doctor_id", "doctor does not exist")
+			app.failedValidationResponse(w, r, v.Errors)
+
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"message": "patient shared successfully"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) unsharePatientHandler(w http.ResponseWriter, r *http.Request) {
+	patient, ok := app.readSharablePatient(w, r)
+	if !ok {
+		return
+	}
+
+	doctorID, err := app.readInt64Param(r, "doctor_id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.models.PatientShares.Delete(patient.ID, doctorID, app.auditEvent(r, data.AuditUnshare, shareChange(&doctorID, nil)))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "patient unshared successfully"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// readSharablePatient loads the patient named in the URL and checks that the
+// caller may manage who it is shared with. Only roles that see every patient
+// and the doctor the patient is assigned to can do so. It writes the error
+// response itself and reports false when the handler should stop.
+func (app *application) readSharablePatient(w http.ResponseWriter, r *http.Request) (*data.Patient, bool) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	patient, err := app.models.Patients.GetByID(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+
+	visibleTo, ok, err := app.patientScope(app.contextGetUser(r))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return nil, false
+	}
+
+	if !ok || (visibleTo != 0 && patient.DoctorID != visibleTo) {
+		app.notPermittedResponse(w, r)
+		return nil, false
+	}
+
+	return patient, true
+}
+
+// shareChange describes a share being added or removed in the same shape as
+// the field-level diffs of patient updates.
+func shareChange(from, to *int64) json.RawMessage {
+	js, _ := json.Marshal(map[string]any{"shared_with": map[string]*int64{"from": from, "to": to}})
+	return js
+}
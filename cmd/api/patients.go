@@ -4,10 +4,12 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/0xMishra/makerble/internal/data"
 	"github.com/0xMishra/makerble/internal/validator"
+	"github.com/julienschmidt/httprouter"
 )
 
 func (app *application) addPatientHandler(w http.ResponseWriter, r *http.Request) {
@@ -42,19 +44,36 @@ func (app *application) addPatientHandler(w http.ResponseWriter, r *http.Request
 	}
 
 	v := validator.New()
-	if data.ValidatePatient(v, patient); !v.Valid() {
+
+	data.ValidatePatient(v, patient)
+
+	err = app.checkDoctorExists(v, patient.DoctorID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
 
-	err = app.models.Patients.Insert(patient)
+	err = app.models.Patients.Insert(patient, app.auditEvent(r, data.AuditCreate, nil))
 	if err != nil {
-		app.serverErrorResponse(w, r, err)
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("doctor_id", "doctor does not exist")
+			app.failedValidationResponse(w, r, v.Errors)
+
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
 		return
 	}
 
 	headers := make(http.Header)
 	headers.Set("Location", fmt.Sprintf("/v1/patients/%d", patient.ID))
+	headers.Set("ETag", patientETag(patient))
 
 	err = app.writeJSON(w, http.StatusCreated, envelope{"patient": patient}, headers)
 	if err != nil {
@@ -62,7 +81,151 @@ func (app *application) addPatientHandler(w http.ResponseWriter, r *http.Request
 	}
 }
 
+func (app *application) listPatientsHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		data.PatientFilters
+		data.Filters
+	}
+
+	v := validator.New()
+
+	qs := r.URL.Query()
+
+	input.Name = app.readString(qs, "name", "")
+	input.Genders = app.readCSV(qs, "gender", []string{})
+	input.DoctorID = int64(app.readInt(qs, "doctor_id", 0, v))
+	input.MinAge = app.readInt(qs, "min_age", 0, v)
+	input.MaxAge = app.readInt(qs, "max_age", 0, v)
+	input.LastVisitFrom = app.readDate(qs, "last_visit_from", time.Time{}, v)
+	input.LastVisitTo = app.readDate(qs, "last_visit_to", time.Time{}, v)
+	input.Archived = app.readBool(qs, "archived", false, v)
+
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+
+	input.Filters.Sort = app.readString(qs, "sort", "id")
+	input.Filters.SortSafelist = []string{"id", "name", "age", "last_visit", "created_at", "-id", "-name", "-age", "-last_visit", "-created_at"}
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	visibleTo, ok, err := app.patientScope(user)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if !ok {
+		app.notPermittedResponse(w, r)
+		return
+	}
+
+	input.VisibleTo = visibleTo
+
+	// Archived patients are only listed to those who may restore them.
+	if input.Archived {
+		allowed, err := app.hasPermission(user, "patients:restore")
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		if !allowed {
+			app.notPermittedResponse(w, r)
+			return
+		}
+	}
+
+	if data.ValidatePatientFilters(v, input.PatientFilters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	patients, metadata, err := app.models.Patients.GetAll(input.PatientFilters, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.audit(r, data.AuditList, patientIDs(patients)...)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"patients": patients, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) searchPatientsHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Query string
+		data.Filters
+	}
+
+	v := validator.New()
+
+	qs := r.URL.Query()
+
+	input.Query = strings.TrimSpace(app.readString(qs, "q", ""))
+
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+
+	input.Filters.Sort = app.readString(qs, "sort", "-rank")
+	input.Filters.SortSafelist = []string{"-rank", "name", "last_visit", "-name", "-last_visit"}
+
+	v.Check(input.Query != "", "q", "must be provided")
+	v.Check(len(input.Query) <= 200, "q", "must not be more than 200 bytes long")
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	visibleTo, ok, err := app.patientScope(app.contextGetUser(r))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if !ok {
+		app.notPermittedResponse(w, r)
+		return
+	}
+
+	patients, metadata, err := app.models.Patients.Search(input.Query, visibleTo, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.audit(r, data.AuditSearch, patientIDs(patients)...)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"patients": patients, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// getPatientHandler also serves GET /v1/patients/search, because httprouter
+// doesn't allow a static segment to sit next to the :id wildcard.
 func (app *application) getPatientHandler(w http.ResponseWriter, r *http.Request) {
+	if httprouter.ParamsFromContext(r.Context()).ByName("id") == "search" {
+		app.searchPatientsHandler(w, r)
+		return
+	}
+
 	id, err := app.readIDParam(r)
 	if err != nil || id < 1 {
 		app.notFoundResponse(w, r)
@@ -81,6 +244,32 @@ func (app *application) getPatientHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	allowed, err := app.canAccessPatient(app.contextGetUser(r), patient)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if !allowed {
+		app.notPermittedResponse(w, r)
+		return
+	}
+
+	err = app.audit(r, data.AuditView, patient.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	etag := patientETag(patient)
+	w.Header().Set("ETag", etag)
+
+	// The view is still audited, as the client goes on showing the record.
+	if etagMatches(r.Header.Get("If-None-Match"), etag, true) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
 	err = app.writeJSON(w, http.StatusOK, envelope{"patient": patient}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
@@ -107,6 +296,21 @@ func (app *application) updatePatientHandler(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
+	allowed, err := app.canAccessPatient(app.contextGetUser(r), patient)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if !allowed {
+		app.notPermittedResponse(w, r)
+		return
+	}
+
+	if !app.checkIfMatch(w, r, patientETag(patient)) {
+		return
+	}
+
 	var input struct {
 		Name           *string    `json:"name"`
 		Gender         *string    `json:"gender"`
@@ -125,12 +329,7 @@ func (app *application) updatePatientHandler(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	v := validator.New()
-
-	if data.ValidatePatient(v, patient); !v.Valid() {
-		app.failedValidationResponse(w, r, v.Errors)
-		return
-	}
+	before := *patient
 
 	if input.Name != nil {
 		patient.Name = *input.Name
@@ -160,45 +359,256 @@ func (app *application) updatePatientHandler(w http.ResponseWriter, r *http.Requ
 		patient.DoctorID = *input.DoctorID
 	}
 
-	err = app.models.Patients.Update(patient)
+	v := validator.New()
+
+	data.ValidatePatient(v, patient)
+
+	err = app.checkDoctorChange(v, app.contextGetUser(r), before.DoctorID, patient)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	changes, err := data.PatientChanges(&before, patient)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.models.Patients.Update(patient, app.auditEvent(r, data.AuditUpdate, changes))
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
 			app.notFoundResponse(w, r)
 
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+
 		default:
 			app.serverErrorResponse(w, r, err)
 		}
 		return
 	}
 
-	err = app.writeJSON(w, http.StatusCreated, envelope{"patient": patient}, nil)
+	headers := make(http.Header)
+	headers.Set("ETag", patientETag(patient))
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"patient": patient}, headers)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
 }
 
+// deletePatientHandler archives the patient. The record is only removed for
+// good by the purge job once the retention period has passed.
 func (app *application) deletePatientHandler(w http.ResponseWriter, r *http.Request) {
+	patient, ok := app.readAccessiblePatient(w, r)
+	if !ok {
+		return
+	}
+
+	if !app.checkIfMatch(w, r, patientETag(patient)) {
+		return
+	}
+
+	var input struct {
+		Reason string `json:"reason"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	input.Reason = strings.TrimSpace(input.Reason)
+
+	v := validator.New()
+
+	v.Check(input.Reason != "", "reason", "must be provided")
+	v.Check(len(input.Reason) <= 1000, "reason", "must not be more than 1000 bytes long")
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Patients.Delete(patient, app.contextGetUser(r).Email, input.Reason, app.auditEvent(r, data.AuditDelete, nil))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "patient info deleted successfully"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) restorePatientHandler(w http.ResponseWriter, r *http.Request) {
 	id, err := app.readIDParam(r)
 	if err != nil || id < 1 {
 		app.notFoundResponse(w, r)
 		return
 	}
 
-	err = app.models.Patients.Delete(id)
+	patient, err := app.models.Patients.GetArchivedByID(id)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
 			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
 
+	err = app.models.Patients.Restore(patient, app.auditEvent(r, data.AuditRestore, nil))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
 		default:
 			app.serverErrorResponse(w, r, err)
 		}
 		return
 	}
 
-	err = app.writeJSON(w, http.StatusOK, envelope{"message": "patient info deleted successfully"}, nil)
+	headers := make(http.Header)
+	headers.Set("ETag", patientETag(patient))
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"patient": patient}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// readAccessiblePatient loads the patient named by the :id URL parameter and
+// checks that the authenticated user may access it.
+func (app *application) readAccessiblePatient(w http.ResponseWriter, r *http.Request) (*data.Patient, bool) {
+	id, err := app.readIDParam(r)
+	if err != nil || id < 1 {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	patient, err := app.models.Patients.GetByID(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+
+	allowed, err := app.canAccessPatient(app.contextGetUser(r), patient)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
+		return nil, false
+	}
+
+	if !allowed {
+		app.notPermittedResponse(w, r)
+		return nil, false
 	}
+
+	return patient, true
+}
+
+// patientScope returns the doctor whose patients user is limited to, or 0
+// when their role holds patients:all. Roles holding patients:own instead are
+// limited to the patients assigned to or shared with the user, which only
+// means something for doctors. A role with neither may see no patients at
+// all, so that a new role starts out closed; ok is false then.
+func (app *application) patientScope(user *data.User) (visibleTo int64, ok bool, err error) {
+	permissions, err := app.models.Permissions.GetAllForRole(user.Role)
+	if err != nil {
+		return 0, false, err
+	}
+
+	switch {
+	case permissions.Include("patients:all"):
+		return 0, true, nil
+	case permissions.Include("patients:own"):
+		return user.ID, true, nil
+	default:
+		return 0, false, nil
+	}
+}
+
+// canAccessPatient reports whether user may read or change patient, going by
+// patientScope.
+func (app *application) canAccessPatient(user *data.User, patient *data.Patient) (bool, error) {
+	visibleTo, ok, err := app.patientScope(user)
+	if err != nil || !ok {
+		return false, err
+	}
+
+	if visibleTo == 0 || patient.DoctorID == visibleTo {
+		return true, nil
+	}
+
+	return app.models.PatientShares.Exists(patient.ID, visibleTo)
+}
+
+// checkDoctorChange vets a change of the doctor a patient is assigned to.
+// Users limited to their own patients can't hand patients over, not even ones
+// they only have shared access to, and nobody can assign a patient to a
+// doctor who doesn't exist.
+func (app *application) checkDoctorChange(v *validator.Validator, user *data.User, previousDoctorID int64, patient *data.Patient) error {
+	if patient.DoctorID == previousDoctorID {
+		return nil
+	}
+
+	visibleTo, _, err := app.patientScope(user)
+	if err != nil {
+		return err
+	}
+
+	if visibleTo != 0 {
+		v.AddError("doctor_id", "cannot be changed with access to your own patients only")
+		return nil
+	}
+
+	return app.checkDoctorExists(v, patient.DoctorID)
+}
+
+// checkDoctorExists adds a validation error when there is no doctor with the
+// given ID.
+func (app *application) checkDoctorExists(v *validator.Validator, doctorID int64) error {
+	_, err := app.models.Doctors.GetByID(doctorID)
+	if err != nil {
+		if errors.Is(err, data.ErrRecordNotFound) {
+			v.AddError("doctor_id", "doctor does not exist")
+			return nil
+		}
+		return err
+	}
+
+	return nil
+}
+
+func patientIDs(patients []*data.Patient) []int64 {
+	ids := make([]int64, 0, len(patients))
+	for _, p := range patients {
+		ids = append(ids, p.ID)
+	}
+
+	return ids
 }
@@ -9,13 +9,38 @@ import (
 	"github.com/0xMishra/makerble/internal/validator"
 )
 
+type formattedReceptionist struct {
+	ID        int64
+	CreatedAt time.Time
+	Name      string
+	Email     string
+	Shifts    data.Schedule
+	Activated bool
+}
+
+func formatReceptionist(rec *data.Receptionist) formattedReceptionist {
+	f := formattedReceptionist{
+		ID:        rec.ID,
+		CreatedAt: rec.CreatedAt,
+		Name:      rec.Name,
+		Email:     rec.Email,
+		Activated: rec.Activated,
+	}
+
+	f.Shifts = rec.Shifts
+
+	return f
+}
+
 func (app *application) registerReceptionistHandler(w http.ResponseWriter, r *http.Request) {
 	var input struct {
-		Name       string `json:"name"`
-		Email      string `json:"email"`
-		Password   string `json:"password"`
-		ShiftStart string `json:"shift_start"`
-		ShiftEnd   string `json:"shift_end"`
+		Name            string        `json:"name"`
+		Email           string        `json:"email"`
+		Password        string        `json:"password"`
+		Shifts          data.Schedule `json:"shifts"`
+		ShiftStart      *string       `json:"shift_start"`
+		ShiftEnd        *string       `json:"shift_end"`
+		InvitationToken string        `json:"invitation_token"`
 	}
 
 	err := app.readJSON(w, r, &input)
@@ -24,23 +49,23 @@ func (app *application) registerReceptionistHandler(w http.ResponseWriter, r *ht
 		return
 	}
 
-	sStart, err := app.parseShiftTiming(input.ShiftStart)
+	allowed, err := app.registrationAllowed(r, "receptionist", input.Email, input.InvitationToken)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
 	}
 
-	sEnd, err := app.parseShiftTiming(input.ShiftEnd)
-	if err != nil {
-		app.serverErrorResponse(w, r, err)
+	if !allowed {
+		app.notPermittedResponse(w, r)
 		return
 	}
 
+	v := validator.New()
+
 	rec := &data.Receptionist{
-		Name:       input.Name,
-		Email:      input.Email,
-		ShiftStart: sStart,
-		ShiftEnd:   sEnd,
+		Name:   input.Name,
+		Email:  input.Email,
+		Shifts: app.readShifts(v, input.Shifts, input.ShiftStart, input.ShiftEnd),
 	}
 
 	err = rec.Password.Set(input.Password)
@@ -49,52 +74,259 @@ func (app *application) registerReceptionistHandler(w http.ResponseWriter, r *ht
 		return
 	}
 
+	if data.ValidateReceptionist(v, rec); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Receptionists.Insert(rec)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateEmail):
+			v.AddError("email", "a user with this email address exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.models.Tokens.DeleteAllForUser(data.ScopeInvitation, rec.Email, "receptionist")
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	token, err := app.models.Tokens.New(3*24*time.Hour, rec.Email, "receptionist", data.ScopeActivation)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.background(func() {
+		tmplData := map[string]any{
+			"name":            rec.Name,
+			"role":            "receptionist",
+			"activationToken": token.Plaintext,
+		}
+
+		err := app.mailer.Send(rec.Email, "user_welcome.tmpl", tmplData)
+		if err != nil {
+			app.logger.Error(err.Error())
+		}
+	})
+
+	err = app.writeJSON(w, http.StatusAccepted, envelope{"receptionist": formatReceptionist(rec)}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) listReceptionistsHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Name string
+		data.Filters
+	}
+
 	v := validator.New()
 
+	qs := r.URL.Query()
+
+	input.Name = app.readString(qs, "name", "")
+
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+
+	input.Filters.Sort = app.readString(qs, "sort", "id")
+	input.Filters.SortSafelist = []string{"id", "name", "created_at", "-id", "-name", "-created_at"}
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	receptionists, metadata, err := app.models.Receptionists.GetAll(input.Name, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	formatted := make([]formattedReceptionist, 0, len(receptionists))
+	for _, rec := range receptionists {
+		formatted = append(formatted, formatReceptionist(rec))
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"receptionists": formatted, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) getReceptionistHandler(w http.ResponseWriter, r *http.Request) {
+	rec, ok := app.readReceptionist(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.writeJSON(w, http.StatusOK, envelope{"receptionist": formatReceptionist(rec)}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) updateReceptionistHandler(w http.ResponseWriter, r *http.Request) {
+	rec, ok := app.readReceptionist(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Name       *string       `json:"name"`
+		Email      *string       `json:"email"`
+		Shifts     data.Schedule `json:"shifts"`
+		ShiftStart *string       `json:"shift_start"`
+		ShiftEnd   *string       `json:"shift_end"`
+		Activated  *bool         `json:"activated"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	previousEmail := rec.Email
+
+	if input.Name != nil {
+		rec.Name = *input.Name
+	}
+	if input.Email != nil {
+		rec.Email = *input.Email
+	}
+	if input.Activated != nil {
+		rec.Activated = *input.Activated
+	}
+
+	v := validator.New()
+
+	if shifts := app.readShifts(v, input.Shifts, input.ShiftStart, input.ShiftEnd); shifts != nil {
+		rec.Shifts = shifts
+	}
+
 	if data.ValidateReceptionist(v, rec); !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
 
-	err = app.models.Receptionists.Insert(rec)
+	err = app.models.Receptionists.Update(rec)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrDuplicateEmail):
 			v.AddError("email", "a user with this email address exists")
 			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
 		default:
 			app.serverErrorResponse(w, r, err)
 		}
 		return
 	}
 
-	token, err := app.models.Tokens.New(3*24*time.Hour, rec.Email, "receptionist", data.ScopeAuthentication)
+	// Tokens are tied to an email address, so a deactivated account or a
+	// changed address must not keep its existing sessions.
+	if !rec.Activated || rec.Email != previousEmail {
+		err = app.models.Tokens.RevokeAllForUser(previousEmail, "receptionist")
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	if rec.Email != previousEmail {
+		err = app.models.TwoFactor.ChangeEmail("receptionist", previousEmail, rec.Email)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"receptionist": formatReceptionist(rec)}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) resetReceptionistPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	rec, ok := app.readReceptionist(w, r)
+	if !ok {
 		return
 	}
 
-	type formattedReceptionist struct {
-		ID         int64
-		CreatedAt  time.Time
-		Name       string
-		Email      string
-		ShiftStart string
-		ShiftEnd   string
+	var input struct {
+		Password string `json:"password"`
 	}
 
-	f := formattedReceptionist{
-		ID:        rec.ID,
-		CreatedAt: rec.CreatedAt,
-		Name:      rec.Name,
-		Email:     rec.Email,
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	err = rec.Password.Set(input.Password)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	if data.ValidateReceptionist(v, rec); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Receptionists.Update(rec)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
 	}
 
-	f.ShiftStart = rec.ShiftStart.Format("3:04 PM")
-	f.ShiftEnd = rec.ShiftEnd.Format("3:04 PM")
+	err = app.models.Tokens.RevokeAllForUser(rec.Email, "receptionist")
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
 
-	err = app.writeJSON(w, http.StatusCreated, envelope{"receptionist": f, "token": token}, nil)
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "password reset successfully"}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
 }
+
+// readReceptionist loads the receptionist named by the :id URL parameter and
+// writes the error response itself when that fails.
+func (app *application) readReceptionist(w http.ResponseWriter, r *http.Request) (*data.Receptionist, bool) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	rec, err := app.models.Receptionists.GetByID(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+
+	return rec, true
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/0xMishra/makerble/internal/data"
+	"github.com/0xMishra/makerble/internal/validator"
+)
+
+type rotaEntry struct {
+	Role  string `json:"role"`
+	ID    int64  `json:"id"`
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+type rotaStaff struct {
+	rotaEntry
+	shifts data.Schedule
+}
+
+// rotaHandler lists the activated staff who are on duty or on call at the
+// given time, defaulting to now. Staff on approved leave or a public holiday
+// are left off duty, but an on-call assignment still lists them as on call.
+func (app *application) rotaHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+
+	qs := r.URL.Query()
+
+	at := time.Now()
+
+	if s := qs.Get("at"); s != "" {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			v.AddError("at", "must be a time in the RFC 3339 format")
+		}
+		at = t
+	}
+
+	role := app.readString(qs, "role", "")
+	if role != "" {
+		v.Check(validator.PermittedValue(role, "doctor", "receptionist"), "role", "must be doctor or receptionist")
+	}
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	var staff []rotaStaff
+
+	if role == "" || role == "doctor" {
+		doctors, err := app.allDoctors()
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		for _, d := range doctors {
+			if d.Activated {
+				staff = append(staff, rotaStaff{rotaEntry{Role: "doctor", ID: d.ID, Name: d.Name, Email: d.Email}, d.Shifts})
+			}
+		}
+	}
+
+	if role == "" || role == "receptionist" {
+		receptionists, err := app.allReceptionists()
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		for _, rec := range receptionists {
+			if rec.Activated {
+				staff = append(staff, rotaStaff{rotaEntry{Role: "receptionist", ID: rec.ID, Name: rec.Name, Email: rec.Email}, rec.Shifts})
+			}
+		}
+	}
+
+	ids := map[string][]int64{}
+	for _, s := range staff {
+		ids[s.Role] = append(ids[s.Role], s.ID)
+	}
+
+	type staffKey struct {
+		role string
+		id   int64
+	}
+
+	away := make(map[staffKey]bool)
+	calledIn := make(map[staffKey]bool)
+	holiday := false
+
+	for staffRole, staffIDs := range ids {
+		exceptions, err := app.models.Exceptions.GetApproved(staffRole, staffIDs, at, at.Add(time.Second))
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		for _, e := range exceptions {
+			switch e.Kind {
+			case data.ExceptionHoliday:
+				holiday = true
+			case data.ExceptionLeave:
+				away[staffKey{e.StaffRole, e.StaffID}] = true
+			case data.ExceptionOnCall:
+				calledIn[staffKey{e.StaffRole, e.StaffID}] = true
+			}
+		}
+	}
+
+	onDuty := []rotaEntry{}
+	onCall := []rotaEntry{}
+
+	for _, s := range staff {
+		key := staffKey{s.Role, s.ID}
+
+		if !holiday && !away[key] && s.shifts.OnDuty(at) {
+			onDuty = append(onDuty, s.rotaEntry)
+		}
+
+		if calledIn[key] {
+			onCall = append(onCall, s.rotaEntry)
+		}
+	}
+
+	err := app.writeJSON(w, http.StatusOK, envelope{"rota": envelope{"at": at, "on_duty": onDuty, "on_call": onCall}}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// allDoctors pages through every doctor.
+func (app *application) allDoctors() ([]*data.Doctor, error) {
+	filters := data.Filters{Page: 1, PageSize: 100, Sort: "id", SortSafelist: []string{"id"}}
+
+	var all []*data.Doctor
+
+	for {
+		doctors, metadata, err := app.models.Doctors.GetAll("", "", false, filters)
+		if err != nil {
+			return nil, err
+		}
+
+		all = append(all, doctors...)
+
+		if filters.Page >= metadata.LastPage {
+			return all, nil
+		}
+
+		filters.Page++
+	}
+}
+
+// allReceptionists pages through every receptionist.
+func (app *application) allReceptionists() ([]*data.Receptionist, error) {
+	filters := data.Filters{Page: 1, PageSize: 100, Sort: "id", SortSafelist: []string{"id"}}
+
+	var all []*data.Receptionist
+
+	for {
+		receptionists, metadata, err := app.models.Receptionists.GetAll("", filters)
+		if err != nil {
+			return nil, err
+		}
+
+		all = append(all, receptionists...)
+
+		if filters.Page >= metadata.LastPage {
+			return all, nil
+		}
+
+		filters.Page++
+	}
+}
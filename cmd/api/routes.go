@@ -19,12 +19,88 @@ func (app *application) routes() http.Handler {
 	router.MethodNotAllowed = http.HandlerFunc(app.methodNotAllowedResponse)
 
 	router.HandlerFunc(http.MethodGet, "/v1/healthcheck", app.healthcheckHandler)
-	router.HandlerFunc(http.MethodPost, "/v1/register", app.registerHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/register", app.idempotent(app.registerHandler))
+	router.HandlerFunc(http.MethodPut, "/v1/users/activated", app.activateUserHandler)
+	router.HandlerFunc(http.MethodPut, "/v1/users/password", app.updateUserPasswordHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/tokens/authentication", app.createAuthenticationTokenHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/tokens/refresh", app.refreshTokenHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/tokens/two-factor", app.createTwoFactorTokenHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/tokens/password-reset", app.createPasswordResetTokenHandler)
+	router.HandlerFunc(http.MethodDelete, "/v1/tokens/current", app.requireAuthenticatedUser(app.deleteCurrentTokenHandler))
 
-	router.HandlerFunc(http.MethodPost, "/v1/patients", app.authenticate("receptionist", "", app.addPatientHandler))
-	router.HandlerFunc(http.MethodGet, "/v1/patients/:id", app.authenticate("receptionist", "doctor", app.getPatientHandler))
-	router.HandlerFunc(http.MethodPut, "/v1/patients/:id", app.authenticate("receptionist", "doctor", app.updatePatientHandler))
-	router.HandlerFunc(http.MethodDelete, "/v1/patients/:id", app.authenticate("receptionist", "", app.deletePatientHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/sessions", app.requireAuthenticatedUser(app.listSessionsHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/sessions/:id", app.requireAuthenticatedUser(app.deleteSessionHandler))
 
-	return app.recoverPanic(app.enableCORS(app.rateLimit(router)))
+	router.HandlerFunc(http.MethodPost, "/v1/users/two-factor", app.requireActivatedUser(app.beginTwoFactorHandler))
+	router.HandlerFunc(http.MethodPut, "/v1/users/two-factor", app.requireActivatedUser(app.confirmTwoFactorHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/users/two-factor", app.requireActivatedUser(app.disableTwoFactorHandler))
+
+	router.HandlerFunc(http.MethodGet, "/v1/patients", app.requirePermission("patients:read", app.listPatientsHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/patients", app.requirePermission("patients:create", app.requireOnShift(app.idempotent(app.addPatientHandler))))
+	router.HandlerFunc(http.MethodGet, "/v1/patients/:id", app.requirePermission("patients:read", app.getPatientHandler))
+	router.HandlerFunc(http.MethodPut, "/v1/patients/:id", app.requirePermission("patients:write", app.requireOnShift(app.updatePatientHandler)))
+	router.HandlerFunc(http.MethodPatch, "/v1/patients/:id", app.requirePermission("patients:write", app.requireOnShift(app.patchPatientHandler)))
+	router.HandlerFunc(http.MethodDelete, "/v1/patients/:id", app.requirePermission("patients:delete", app.requireOnShift(app.deletePatientHandler)))
+	router.HandlerFunc(http.MethodPost, "/v1/patients/:id/restore", app.requirePermission("patients:restore", app.requireOnShift(app.restorePatientHandler)))
+
+	router.HandlerFunc(http.MethodGet, "/v1/patients/:id/versions", app.requirePermission("patients:read", app.listPatientVersionsHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/patients/:id/versions/:version", app.requirePermission("patients:read", app.showPatientVersionHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/patients/:id/versions/:version/revert", app.requirePermission("patients:write", app.requireOnShift(app.revertPatientHandler)))
+	router.HandlerFunc(http.MethodGet, "/v1/patients/:id/audit", app.requirePermission("audit:read", app.listPatientAuditHandler))
+
+	router.HandlerFunc(http.MethodPost, "/v1/patients/:id/shares", app.requirePermission("patients:write", app.requireOnShift(app.sharePatientHandler)))
+	router.HandlerFunc(http.MethodDelete, "/v1/patients/:id/shares/:doctor_id", app.requirePermission("patients:write", app.requireOnShift(app.unsharePatientHandler)))
+
+	router.HandlerFunc(http.MethodGet, "/v1/appointments", app.requirePermission("appointments:read", app.listAppointmentsHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/appointments", app.requirePermission("appointments:write", app.requireOnShift(app.idempotent(app.createAppointmentHandler))))
+	router.HandlerFunc(http.MethodGet, "/v1/appointments/:id", app.requirePermission("appointments:read", app.getAppointmentHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/appointments/:id", app.requirePermission("appointments:write", app.requireOnShift(app.updateAppointmentHandler)))
+
+	router.HandlerFunc(http.MethodPost, "/v1/invitations", app.requirePermission("users:write", app.createInvitationHandler))
+
+	router.HandlerFunc(http.MethodGet, "/v1/doctors", app.requirePermission("users:read", app.listDoctorsHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/doctors/:id", app.requirePermission("users:read", app.getDoctorHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/doctors/:id", app.requirePermission("users:write", app.updateDoctorHandler))
+	router.HandlerFunc(http.MethodPut, "/v1/doctors/:id/password", app.requirePermission("users:write", app.resetDoctorPasswordHandler))
+
+	router.HandlerFunc(http.MethodGet, "/v1/doctors/:id/availability", app.requirePermission("appointments:read", app.doctorAvailabilityHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/availability", app.requirePermission("appointments:read", app.searchAvailabilityHandler))
+
+	router.HandlerFunc(http.MethodGet, "/v1/doctors/:id/breaks", app.requirePermission("schedules:read", app.listDoctorBreaksHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/doctors/:id/breaks", app.requirePermission("schedules:write", app.createDoctorBreakHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/doctors/:id/breaks/:break_id", app.requirePermission("schedules:write", app.deleteDoctorBreakHandler))
+
+	router.HandlerFunc(http.MethodGet, "/v1/leave", app.requirePermission("schedules:read", app.listLeaveHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/leave", app.requirePermission("leave:request", app.createLeaveHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/leave/:id", app.requirePermission("leave:approve", app.reviewLeaveHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/leave/:id", app.requirePermission("leave:request", app.deleteLeaveHandler))
+
+	router.HandlerFunc(http.MethodGet, "/v1/holidays", app.requirePermission("schedules:read", app.listHolidaysHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/holidays", app.requirePermission("schedules:write", app.createHolidayHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/holidays/:id", app.requirePermission("schedules:write", app.deleteHolidayHandler))
+
+	router.HandlerFunc(http.MethodGet, "/v1/on-call", app.requirePermission("schedules:read", app.listOnCallHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/on-call", app.requirePermission("schedules:write", app.createOnCallHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/on-call/:id", app.requirePermission("schedules:write", app.deleteOnCallHandler))
+
+	router.HandlerFunc(http.MethodGet, "/v1/rota", app.requirePermission("schedules:read", app.rotaHandler))
+
+	router.HandlerFunc(http.MethodGet, "/v1/receptionists", app.requirePermission("users:read", app.listReceptionistsHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/receptionists/:id", app.requirePermission("users:read", app.getReceptionistHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/receptionists/:id", app.requirePermission("users:write", app.updateReceptionistHandler))
+	router.HandlerFunc(http.MethodPut, "/v1/receptionists/:id/password", app.requirePermission("users:write", app.resetReceptionistPasswordHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/receptionists/:id/shift-overrides", app.requirePermission("shifts:override", app.listShiftOverridesHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/receptionists/:id/shift-overrides", app.requirePermission("shifts:override", app.createShiftOverrideHandler))
+
+	router.HandlerFunc(http.MethodPost, "/v1/users/unlock", app.requirePermission("users:unlock", app.unlockUserHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/security-events", app.requirePermission("security:read", app.listSecurityEventsHandler))
+
+	router.HandlerFunc(http.MethodGet, "/v1/permissions", app.requirePermission("permissions:read", app.listPermissionsHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/roles/:role/permissions", app.requirePermission("permissions:read", app.listRolePermissionsHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/roles/:role/permissions", app.requirePermission("permissions:write", app.grantRolePermissionsHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/roles/:role/permissions/:code", app.requirePermission("permissions:write", app.revokeRolePermissionHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/roles/:role/two-factor", app.requirePermission("2fa:manage", app.showRoleTwoFactorHandler))
+	router.HandlerFunc(http.MethodPut, "/v1/roles/:role/two-factor", app.requirePermission("2fa:manage", app.updateRoleTwoFactorHandler))
+
+	return app.recoverPanic(app.requestID(app.enableCORS(app.rateLimit(app.authenticate(router)))))
 }
@@ -0,0 +1,470 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/0xMishra/makerble/internal/data"
+	"github.com/0xMishra/makerble/internal/validator"
+)
+
+func (app *application) listLeaveHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+
+	qs := r.URL.Query()
+
+	ef := data.ScheduleExceptionFilters{
+		Kind:      data.ExceptionLeave,
+		StaffRole: app.readString(qs, "staff_role", ""),
+		StaffID:   int64(app.readInt(qs, "staff_id", 0, v)),
+		Status:    app.readString(qs, "status", ""),
+	}
+
+	if ef.Status != "" {
+		v.Check(validator.PermittedValue(ef.Status, data.ExceptionPending, data.ExceptionApproved, data.ExceptionRejected), "status", "must be pending, approved or rejected")
+	}
+
+	// Leave reasons are private, so staff who neither review leave nor
+	// manage schedules only see their own requests.
+	user := app.contextGetUser(r)
+
+	reviewer, err := app.hasPermission(user, "leave:approve")
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	manager, err := app.hasPermission(user, "schedules:write")
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if !reviewer && !manager {
+		ef.StaffRole = user.Role
+		ef.StaffID = user.ID
+	}
+
+	app.listScheduleExceptions(w, r, v, ef, "leave")
+}
+
+func (app *application) createLeaveHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		StaffRole string `json:"staff_role"`
+		StaffID   int64  `json:"staff_id"`
+		StartsOn  string `json:"starts_on"`
+		EndsOn    string `json:"ends_on"`
+		Reason    string `json:"reason"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	// Without a staff member the request is for the caller's own leave.
+	// Admins aren't on the rota, so they can only request leave for others.
+	if input.StaffRole == "" && input.StaffID == 0 {
+		if !rostered(user.Role) {
+			v := validator.New()
+			v.AddError("staff_id", "must be provided, as your role has no rota to take leave from")
+			app.failedValidationResponse(w, r, v.Errors)
+			return
+		}
+
+		input.StaffRole = user.Role
+		input.StaffID = user.ID
+	}
+
+	if input.StaffRole != user.Role || input.StaffID != user.ID {
+		manager, err := app.hasPermission(user, "schedules:write")
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		if !manager {
+			app.notPermittedResponse(w, r)
+			return
+		}
+	}
+
+	v := validator.New()
+
+	e := &data.ScheduleException{
+		Kind:        data.ExceptionLeave,
+		StaffRole:   input.StaffRole,
+		StaffID:     input.StaffID,
+		Status:      data.ExceptionPending,
+		Reason:      input.Reason,
+		RequestedBy: user.Email,
+	}
+
+	e.StartsAt, e.EndsAt = app.readDateRange(v, input.StartsOn, input.EndsOn)
+
+	app.createScheduleException(w, r, v, e, "leave")
+}
+
+// reviewLeaveHandler approves or rejects a pending leave request.
+func (app *application) reviewLeaveHandler(w http.ResponseWriter, r *http.Request) {
+	e, ok := app.readScheduleException(w, r, data.ExceptionLeave)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Status string `json:"status"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	v.Check(e.Status == data.ExceptionPending, "status", "leave has already been reviewed")
+	v.Check(validator.PermittedValue(input.Status, data.ExceptionApproved, data.ExceptionRejected), "status", "must be approved or rejected")
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	now := time.Now()
+
+	e.Status = input.Status
+	e.ReviewedBy = app.contextGetUser(r).Email
+	e.ReviewedAt = &now
+
+	err = app.models.Exceptions.Update(e)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"leave": e}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// deleteLeaveHandler withdraws a leave request. Staff can withdraw their own
+// requests until they are reviewed, reviewers can remove any leave.
+func (app *application) deleteLeaveHandler(w http.ResponseWriter, r *http.Request) {
+	e, ok := app.readScheduleException(w, r, data.ExceptionLeave)
+	if !ok {
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	reviewer, err := app.hasPermission(user, "leave:approve")
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	own := e.StaffRole == user.Role && e.StaffID == user.ID
+
+	if !reviewer && (!own || e.Status != data.ExceptionPending) {
+		app.notPermittedResponse(w, r)
+		return
+	}
+
+	app.deleteScheduleException(w, r, e, "leave")
+}
+
+func (app *application) listHolidaysHandler(w http.ResponseWriter, r *http.Request) {
+	ef := data.ScheduleExceptionFilters{
+		Kind: data.ExceptionHoliday,
+	}
+
+	app.listScheduleExceptions(w, r, validator.New(), ef, "holidays")
+}
+
+func (app *application) createHolidayHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		StartsOn string `json:"starts_on"`
+		EndsOn   string `json:"ends_on"`
+		Reason   string `json:"reason"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	// A single day holiday only needs starts_on.
+	if input.EndsOn == "" {
+		input.EndsOn = input.StartsOn
+	}
+
+	v := validator.New()
+
+	e := &data.ScheduleException{
+		Kind:        data.ExceptionHoliday,
+		Status:      data.ExceptionApproved,
+		Reason:      input.Reason,
+		RequestedBy: app.contextGetUser(r).Email,
+	}
+
+	e.StartsAt, e.EndsAt = app.readDateRange(v, input.StartsOn, input.EndsOn)
+
+	app.createScheduleException(w, r, v, e, "holiday")
+}
+
+func (app *application) deleteHolidayHandler(w http.ResponseWriter, r *http.Request) {
+	e, ok := app.readScheduleException(w, r, data.ExceptionHoliday)
+	if !ok {
+		return
+	}
+
+	app.deleteScheduleException(w, r, e, "holiday")
+}
+
+func (app *application) listOnCallHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+
+	qs := r.URL.Query()
+
+	ef := data.ScheduleExceptionFilters{
+		Kind:      data.ExceptionOnCall,
+		StaffRole: app.readString(qs, "staff_role", ""),
+		StaffID:   int64(app.readInt(qs, "staff_id", 0, v)),
+	}
+
+	app.listScheduleExceptions(w, r, v, ef, "on_call")
+}
+
+func (app *application) createOnCallHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		StaffRole string    `json:"staff_role"`
+		StaffID   int64     `json:"staff_id"`
+		StartsAt  time.Time `json:"starts_at"`
+		EndsAt    time.Time `json:"ends_at"`
+		Reason    string    `json:"reason"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	e := &data.ScheduleException{
+		Kind:        data.ExceptionOnCall,
+		StaffRole:   input.StaffRole,
+		StaffID:     input.StaffID,
+		StartsAt:    input.StartsAt,
+		EndsAt:      input.EndsAt,
+		Status:      data.ExceptionApproved,
+		Reason:      input.Reason,
+		RequestedBy: app.contextGetUser(r).Email,
+	}
+
+	app.createScheduleException(w, r, validator.New(), e, "on_call")
+}
+
+func (app *application) deleteOnCallHandler(w http.ResponseWriter, r *http.Request) {
+	e, ok := app.readScheduleException(w, r, data.ExceptionOnCall)
+	if !ok {
+		return
+	}
+
+	app.deleteScheduleException(w, r, e, "on_call")
+}
+
+// listScheduleExceptions reads the from and to dates and the paging
+// parameters, then writes the matching exceptions under key.
+func (app *application) listScheduleExceptions(w http.ResponseWriter, r *http.Request, v *validator.Validator, ef data.ScheduleExceptionFilters, key string) {
+	var filters data.Filters
+
+	qs := r.URL.Query()
+
+	ef.From, ef.To = app.readDateFilter(qs, v)
+
+	filters.Page = app.readInt(qs, "page", 1, v)
+	filters.PageSize = app.readInt(qs, "page_size", 20, v)
+
+	filters.Sort = app.readString(qs, "sort", "starts_at")
+	filters.SortSafelist = []string{"id", "starts_at", "created_at", "-id", "-starts_at", "-created_at"}
+
+	if data.ValidateFilters(v, filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	exceptions, metadata, err := app.models.Exceptions.GetAll(ef, filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{key: exceptions, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// createScheduleException validates e, checks that its staff member exists
+// and stores it, writing it back under key.
+func (app *application) createScheduleException(w http.ResponseWriter, r *http.Request, v *validator.Validator, e *data.ScheduleException, key string) {
+	// Dates that failed to parse have already been reported.
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if data.ValidateScheduleException(v, e); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if e.Kind != data.ExceptionHoliday {
+		exists, err := app.staffExists(e.StaffRole, e.StaffID)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		if !exists {
+			v.AddError("staff_id", "staff member does not exist")
+			app.failedValidationResponse(w, r, v.Errors)
+			return
+		}
+	}
+
+	err := app.models.Exceptions.Insert(e)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{key: e}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteScheduleException(w http.ResponseWriter, r *http.Request, e *data.ScheduleException, name string) {
+	err := app.models.Exceptions.Delete(e.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": name + " deleted successfully"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// readScheduleException loads the exception named by the :id URL parameter,
+// treating one of another kind as missing.
+func (app *application) readScheduleException(w http.ResponseWriter, r *http.Request, kind string) (*data.ScheduleException, bool) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	e, err := app.models.Exceptions.GetByID(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+
+	if e.Kind != kind {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	return e, true
+}
+
+// readDateRange turns two inclusive dates into the span from the start of the
+// first to the end of the last day, in the server's local time zone.
+func (app *application) readDateRange(v *validator.Validator, startsOn, endsOn string) (time.Time, time.Time) {
+	start, err := time.Parse(time.DateOnly, startsOn)
+	v.Check(err == nil, "starts_on", "must be a date in the YYYY-MM-DD format")
+
+	end, err := time.Parse(time.DateOnly, endsOn)
+	v.Check(err == nil, "ends_on", "must be a date in the YYYY-MM-DD format")
+
+	if !v.Valid() {
+		return time.Time{}, time.Time{}
+	}
+
+	return localDate(start), localDate(end).AddDate(0, 0, 1)
+}
+
+// readDateFilter reads the optional, inclusive from and to query dates.
+func (app *application) readDateFilter(qs url.Values, v *validator.Validator) (time.Time, time.Time) {
+	from := app.readDate(qs, "from", time.Time{}, v)
+	to := app.readDate(qs, "to", time.Time{}, v)
+
+	if !from.IsZero() {
+		from = localDate(from)
+	}
+
+	// to is inclusive, so the range runs until the end of that day.
+	if !to.IsZero() {
+		to = localDate(to).AddDate(0, 0, 1)
+	}
+
+	return from, to
+}
+
+// rostered reports whether staff with role work shifts, and so can take leave
+// or be put on call.
+func rostered(role string) bool {
+	return role == "doctor" || role == "receptionist"
+}
+
+func (app *application) staffExists(role string, id int64) (bool, error) {
+	var err error
+
+	switch role {
+	case "doctor":
+		_, err = app.models.Doctors.GetByID(id)
+	case "receptionist":
+		_, err = app.models.Receptionists.GetByID(id)
+	default:
+		return false, nil
+	}
+
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.Is(err, data.ErrRecordNotFound):
+		return false, nil
+	default:
+		return false, err
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/0xMishra/makerble/internal/data"
+	"github.com/0xMishra/makerble/internal/validator"
+)
+
+func (app *application) listDoctorBreaksHandler(w http.ResponseWriter, r *http.Request) {
+	d, ok := app.readDoctor(w, r)
+	if !ok {
+		return
+	}
+
+	breaks, err := app.models.DoctorBreaks.GetAllForDoctor(d.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"breaks": breaks}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) createDoctorBreakHandler(w http.ResponseWriter, r *http.Request) {
+	d, ok := app.readDoctor(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Weekday   *int   `json:"weekday"`
+		StartTime string `json:"start_time"`
+		EndTime   string `json:"end_time"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	b := &data.DoctorBreak{
+		DoctorID:  d.ID,
+		Weekday:   input.Weekday,
+		StartTime: input.StartTime,
+		EndTime:   input.EndTime,
+	}
+
+	v := validator.New()
+
+	if data.ValidateDoctorBreak(v, b); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.DoctorBreaks.Insert(b)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"break": b}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteDoctorBreakHandler(w http.ResponseWriter, r *http.Request) {
+	doctorID, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	breakID, err := app.readInt64Param(r, "break_id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.models.DoctorBreaks.Delete(breakID, doctorID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "break deleted successfully"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"net/http"
+
+	"github.com/0xMishra/makerble/internal/data"
+	"github.com/0xMishra/makerble/internal/validator"
+)
+
+// loginPolicies returns the throttling policies for email addresses and for
+// client IPs. An IP gets more attempts than an email address, since several
+// people may sign in from behind the same address.
+func (app *application) loginPolicies() (data.ThrottlePolicy, data.ThrottlePolicy) {
+	email := data.ThrottlePolicy{
+		MaxFailures: app.config.login.maxFailures,
+		BaseDelay:   app.config.login.delay,
+		Lockout:     app.config.login.lockout,
+	}
+
+	ip := email
+	ip.MaxFailures = app.config.login.ipMaxFailures
+
+	return email, ip
+}
+
+// loginAttempt is an attempt to sign in as an email, reserved against both
+// the email and the client IP. It counts as a failure until it is settled.
+type loginAttempt struct {
+	email *data.LoginAttempt
+	ip    *data.LoginAttempt
+}
+
+// beginLoginAttempt reserves an attempt to sign in as email before the
+// credentials are checked, so that further guesses from throttled clients
+// tell them nothing. When the email or the client IP still has to wait, it
+// answers the request itself and returns nil.
+func (app *application) beginLoginAttempt(w http.ResponseWriter, r *http.Request, email string) *loginAttempt {
+	emailPolicy, ipPolicy := app.loginPolicies()
+
+	ip, wait, err := app.models.Throttles.Attempt(data.ThrottleIP, clientIP(r), ipPolicy)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return nil
+	}
+
+	var a *data.LoginAttempt
+
+	if wait == 0 {
+		a, wait, err = app.models.Throttles.Attempt(data.ThrottleEmail, email, emailPolicy)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return nil
+		}
+
+		// The IP was counted, but the attempt won't be made after all.
+		if wait > 0 {
+			err = app.models.Throttles.Release(ip, ipPolicy)
+			if err != nil {
+				app.serverErrorResponse(w, r, err)
+				return nil
+			}
+		}
+	}
+
+	if wait > 0 {
+		err = app.securityEvent(r, data.SecurityLoginBlocked, email, "", "")
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return nil
+		}
+
+		app.tooManyLoginAttemptsResponse(w, r, wait)
+		return nil
+	}
+
+	return &loginAttempt{email: a, ip: ip}
+}
+
+// loginFailed settles an attempt whose password or two-factor code was wrong.
+// When the attempt locked the email out, the owners of the accounts
+// registered under it are told by email.
+func (app *application) loginFailed(r *http.Request, a *loginAttempt, kind, role string) error {
+	email := a.email.Subject
+
+	err := app.securityEvent(r, kind, email, role, "")
+	if err != nil {
+		return err
+	}
+
+	if a.email.Locked {
+		err = app.securityEvent(r, data.SecurityAccountLocked, email, role, "too many failed logins for this email")
+		if err != nil {
+			return err
+		}
+
+		err = app.notifyAccountLocked(r, email)
+		if err != nil {
+			return err
+		}
+	}
+
+	if a.ip.Locked {
+		app.logger.Warn("client IP locked out after failed logins", "ip", a.ip.Subject)
+
+		return app.securityEvent(r, data.SecurityAccountLocked, email, role, "too many failed logins from this IP")
+	}
+
+	return nil
+}
+
+// loginSucceeded settles an attempt that signed the user in. The failures
+// counted against the email are forgotten, but from the client IP only this
+// attempt is taken back; its other failures are left to expire, so that
+// signing in to one account doesn't buy more guesses at others.
+func (app *application) loginSucceeded(r *http.Request, a *loginAttempt, role string) error {
+	err := app.securityEvent(r, data.SecurityLoginSucceeded, a.email.Subject, role, "")
+	if err != nil {
+		return err
+	}
+
+	_, err = app.models.Throttles.Clear(data.ThrottleEmail, a.email.Subject)
+	if err != nil {
+		return err
+	}
+
+	_, ipPolicy := app.loginPolicies()
+
+	return app.models.Throttles.Release(a.ip, ipPolicy)
+}
+
+// releaseLoginAttempt takes back an attempt whose credentials were right but
+// which didn't sign anyone in, such as a password that still needs a
+// two-factor code.
+func (app *application) releaseLoginAttempt(a *loginAttempt) error {
+	emailPolicy, ipPolicy := app.loginPolicies()
+
+	err := app.models.Throttles.Release(a.email, emailPolicy)
+	if err != nil {
+		return err
+	}
+
+	return app.models.Throttles.Release(a.ip, ipPolicy)
+}
+
+func (app *application) securityEvent(r *http.Request, kind, email, role, details string) error {
+	e := &data.SecurityEvent{
+		Kind:      kind,
+		Email:     email,
+		Role:      role,
+		IP:        clientIP(r),
+		UserAgent: r.UserAgent(),
+		Details:   details,
+	}
+
+	return app.models.Security.Insert(e)
+}
+
+// notifyAccountLocked emails every activated account registered under email
+// that it has been locked out, so that the owner learns that someone is
+// guessing their password.
+func (app *application) notifyAccountLocked(r *http.Request, email string) error {
+	ip := clientIP(r)
+
+	for _, role := range []string{"doctor", "receptionist", "admin"} {
+		user, err := app.getUser(role, email)
+		if err != nil {
+			if errors.Is(err, data.ErrRecordNotFound) {
+				continue
+			}
+			return err
+		}
+
+		if !user.Activated {
+			continue
+		}
+
+		app.background(func() {
+			tmplData := map[string]any{
+				"name":    user.Name,
+				"role":    role,
+				"ip":      ip,
+				"lockout": app.config.login.lockout.String(),
+			}
+
+			err := app.mailer.Send(user.Email, "account_locked.tmpl", tmplData)
+			if err != nil {
+				app.logger.Error(err.Error())
+			}
+		})
+	}
+
+	return nil
+}
+
+// unlockUserHandler lifts the lockout of an email address or a client IP
+// before it runs out, and forgets the failed logins counted against it.
+func (app *application) unlockUserHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Email string `json:"email"`
+		IP    string `json:"ip"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	v.Check(input.Email != "" || input.IP != "", "email", "must be provided, or an ip instead")
+	v.Check(input.Email == "" || input.IP == "", "ip", "must not be sent together with email")
+
+	if input.Email != "" {
+		validator.ValidateEmail(v, input.Email)
+	}
+
+	if input.IP != "" {
+		v.Check(net.ParseIP(input.IP) != nil, "ip", "must be a valid IP address")
+	}
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	kind, subject := data.ThrottleEmail, input.Email
+	if input.IP != "" {
+		kind, subject = data.ThrottleIP, input.IP
+	}
+
+	cleared, err := app.models.Throttles.Clear(kind, subject)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if !cleared {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	admin := app.contextGetUser(r)
+
+	err = app.securityEvent(r, data.SecurityAccountUnlocked, input.Email, "", "unlocked "+kind+" "+subject+" by "+admin.Email)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": kind + " unlocked successfully"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) listSecurityEventsHandler(w http.ResponseWriter, r *http.Request) {
+	var filters data.Filters
+
+	v := validator.New()
+
+	qs := r.URL.Query()
+
+	sf := data.SecurityEventFilters{
+		Email: app.readString(qs, "email", ""),
+		Kind:  app.readString(qs, "kind", ""),
+		IP:    app.readString(qs, "ip", ""),
+	}
+
+	if sf.Kind != "" {
+		v.Check(validator.PermittedValue(sf.Kind, data.SecurityEvents...), "kind", "invalid security event kind")
+	}
+
+	filters.Page = app.readInt(qs, "page", 1, v)
+	filters.PageSize = app.readInt(qs, "page_size", 20, v)
+
+	filters.Sort = app.readString(qs, "sort", "-id")
+	filters.SortSafelist = []string{"id", "-id"}
+
+	if data.ValidateFilters(v, filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	events, metadata, err := app.models.Security.GetAll(sf, filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"security_events": events, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
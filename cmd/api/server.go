@@ -24,6 +24,15 @@ func (app *application) serve() error {
 
 	shutdownError := make(chan error)
 
+	sweepCtx, stopSweeper := context.WithCancel(context.Background())
+	defer stopSweeper()
+
+	if app.config.tokens.sweepInterval > 0 {
+		app.background(func() {
+			app.sweepExpired(sweepCtx, app.config.tokens.sweepInterval)
+		})
+	}
+
 	go func() {
 		quit := make(chan os.Signal, 1)
 
@@ -40,6 +49,8 @@ func (app *application) serve() error {
 			shutdownError <- err
 		}
 
+		stopSweeper()
+
 		app.logger.Info("completing background tasks", "addr", srv.Addr)
 
 		app.wg.Wait()
@@ -65,3 +76,32 @@ func (app *application) serve() error {
 	app.logger.Info("stopped server", "addr", srv.Addr)
 	return nil
 }
+
+// sweepExpired removes expired tokens and idempotency keys every interval
+// until ctx is cancelled.
+func (app *application) sweepExpired(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-ticker.C:
+			tokens, err := app.models.Tokens.DeleteExpired(ctx)
+			if err != nil {
+				app.logger.Error(err.Error())
+				continue
+			}
+
+			keys, err := app.models.Idempotency.DeleteExpired(ctx)
+			if err != nil {
+				app.logger.Error(err.Error())
+				continue
+			}
+
+			app.logger.Info("expired rows swept", "tokens", tokens, "idempotency_keys", keys)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/0xMishra/makerble/internal/data"
+	"github.com/0xMishra/makerble/internal/validator"
+)
+
+func (app *application) listShiftOverridesHandler(w http.ResponseWriter, r *http.Request) {
+	rec, ok := app.readReceptionist(w, r)
+	if !ok {
+		return
+	}
+
+	overrides, err := app.models.Overrides.GetAllForReceptionist(rec.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"shift_overrides": overrides}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// createShiftOverrideHandler lets a receptionist make changes outside their
+// shift for a while. The override is stored along with the admin who granted
+// it and the reason given.
+func (app *application) createShiftOverrideHandler(w http.ResponseWriter, r *http.Request) {
+	rec, ok := app.readReceptionist(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		StartsAt *time.Time `json:"starts_at"`
+		EndsAt   time.Time  `json:"ends_at"`
+		Reason   string     `json:"reason"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	o := &data.ShiftOverride{
+		ReceptionistID: rec.ID,
+		StartsAt:       time.Now(),
+		EndsAt:         input.EndsAt,
+		Reason:         input.Reason,
+		GrantedBy:      app.contextGetUser(r).Email,
+	}
+
+	if input.StartsAt != nil {
+		o.StartsAt = *input.StartsAt
+	}
+
+	v := validator.New()
+
+	if data.ValidateShiftOverride(v, o); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Overrides.Insert(o)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"shift_override": o}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/0xMishra/makerble/internal/data"
+	"github.com/0xMishra/makerble/internal/validator"
+)
+
+func (app *application) createAuthenticationTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	validator.ValidateEmail(v, input.Email)
+	validator.ValidatePlaintextPassword(v, input.Password)
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	attempt := app.beginLoginAttempt(w, r, input.Email)
+	if attempt == nil {
+		return
+	}
+
+	role, err := app.matchCredentials(input.Email, input.Password)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if role == "" {
+		err = app.loginFailed(r, attempt, data.SecurityLoginFailed, "")
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		app.invalidCredentialsResponse(w, r)
+		return
+	}
+
+	// With two-factor authentication the password only earns a short-lived
+	// token that has to be exchanged, along with a code, at
+	// POST /v1/tokens/two-factor. The login only counts as a success, and
+	// clears the failed attempts, once the code has been accepted; until then
+	// only this attempt is taken back.
+	enabled, err := app.models.TwoFactor.Enabled(input.Email, role)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if enabled {
+		err = app.releaseLoginAttempt(attempt)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		token, err := app.models.Tokens.New(5*time.Minute, input.Email, role, data.ScopeTwoFactor)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		err = app.writeJSON(w, http.StatusCreated, envelope{"two_factor_token": token}, nil)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.loginSucceeded(r, attempt, role)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	access, refresh, err := app.models.Tokens.NewPair(app.config.tokens.accessTTL, app.config.tokens.refreshTTL, input.Email, role, r.UserAgent(), clientIP(r))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"authentication_token": access, "refresh_token": refresh}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// refreshTokenHandler rotates a refresh token: it is spent and a new
+// authentication and refresh token pair is returned in its place.
+func (app *application) refreshTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	if data.ValidateTokenPlaintext(v, input.RefreshToken); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	access, refresh, err := app.models.Tokens.Rotate(input.RefreshToken, app.config.tokens.accessTTL, app.config.tokens.refreshTTL)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.invalidRefreshTokenResponse(w, r)
+
+		case errors.Is(err, data.ErrTokenReused):
+			app.logger.Warn("refresh token reused, token family revoked", "ip", r.RemoteAddr)
+			app.invalidRefreshTokenResponse(w, r)
+
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"authentication_token": access, "refresh_token": refresh}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// matchCredentials looks the email up among doctors, receptionists and then
+// admins and returns the role of the first account whose password hash
+// matches. An empty role means that no account matched.
+func (app *application) matchCredentials(email, password string) (string, error) {
+	d, err := app.models.Doctors.GetByEmail(email)
+	switch {
+	case err == nil:
+		match, err := d.Password.Matches(password)
+		if err != nil {
+			return "", err
+		}
+
+		if match {
+			return "doctor", nil
+		}
+
+	case !errors.Is(err, data.ErrRecordNotFound):
+		return "", err
+	}
+
+	rec, err := app.models.Receptionists.GetByEmail(email)
+	switch {
+	case err == nil:
+		match, err := rec.Password.Matches(password)
+		if err != nil {
+			return "", err
+		}
+
+		if match {
+			return "receptionist", nil
+		}
+
+	case !errors.Is(err, data.ErrRecordNotFound):
+		return "", err
+	}
+
+	a, err := app.models.Admins.GetByEmail(email)
+	switch {
+	case err == nil:
+		match, err := a.Password.Matches(password)
+		if err != nil {
+			return "", err
+		}
+
+		if match {
+			return "admin", nil
+		}
+
+	case !errors.Is(err, data.ErrRecordNotFound):
+		return "", err
+	}
+
+	return "", nil
+}
+
+// deleteCurrentTokenHandler signs out the session that authenticated the
+// request, revoking its authentication and refresh tokens.
+func (app *application) deleteCurrentTokenHandler(w http.ResponseWriter, r *http.Request) {
+	err := app.models.Sessions.DeleteByFamily(app.contextGetSession(r))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "signed out successfully"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// createPasswordResetTokenHandler emails a password reset token to every
+// activated account registered under the email. The response is the same
+// whether or not there is one, so it can't be used to probe for accounts.
+func (app *application) createPasswordResetTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Email string `json:"email"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	if validator.ValidateEmail(v, input.Email); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	for _, role := range []string{"doctor", "receptionist", "admin"} {
+		user, err := app.getUser(role, input.Email)
+		if err != nil {
+			if errors.Is(err, data.ErrRecordNotFound) {
+				continue
+			}
+
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		if !user.Activated {
+			continue
+		}
+
+		token, err := app.models.Tokens.New(30*time.Minute, user.Email, role, data.ScopePasswordReset)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		app.background(func() {
+			tmplData := map[string]any{
+				"name":               user.Name,
+				"role":               role,
+				"passwordResetToken": token.Plaintext,
+				"expiresIn":          "30 minutes",
+			}
+
+			err := app.mailer.Send(user.Email, "password_reset.tmpl", tmplData)
+			if err != nil {
+				app.logger.Error(err.Error())
+			}
+		})
+	}
+
+	err = app.writeJSON(w, http.StatusAccepted, envelope{"message": "if an account is registered under this email, you will receive an email with password reset instructions"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,356 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/0xMishra/makerble/internal/data"
+	"github.com/0xMishra/makerble/internal/totp"
+	"github.com/0xMishra/makerble/internal/validator"
+)
+
+// totpIssuer is the account label shown in authenticator apps.
+const totpIssuer = "Makerble"
+
+// beginTwoFactorHandler creates a new TOTP secret for the user. It is only
+// used for logins once it has been confirmed with a code.
+func (app *application) beginTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.models.TwoFactor.Begin(user.Email, user.Role, secret)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrTwoFactorEnabled):
+			app.twoFactorAlreadyEnabledResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	env := envelope{
+		"secret":           totp.EncodeSecret(secret),
+		"provisioning_uri": totp.ProvisioningURI(secret, totpIssuer, user.Email),
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, env, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// confirmTwoFactorHandler enables two-factor authentication once the user
+// enters a code from their app, and hands out the recovery codes. They are
+// only ever shown here.
+func (app *application) confirmTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Code string `json:"code"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	if data.ValidateTOTPCode(v, input.Code); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	tf, err := app.models.TwoFactor.Get(user.Email, user.Role)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if tf.ConfirmedAt != nil {
+		app.twoFactorAlreadyEnabledResponse(w, r)
+		return
+	}
+
+	step, ok := totp.Validate(tf.Secret, input.Code, time.Now(), tf.LastUsedStep)
+	if !ok {
+		app.invalidTwoFactorCodeResponse(w, r)
+		return
+	}
+
+	codes, err := app.models.TwoFactor.Confirm(user.Email, user.Role, step)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrTwoFactorEnabled):
+			app.twoFactorAlreadyEnabledResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"recovery_codes": codes}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// disableTwoFactorHandler turns two-factor authentication off. It asks for a
+// current code or a recovery code, so a stolen session can't do it alone.
+func (app *application) disableTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	attempt := app.checkSecondFactor(w, r, user.Email, user.Role, input.Code, input.RecoveryCode)
+	if attempt == nil {
+		return
+	}
+
+	err = app.releaseLoginAttempt(attempt)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.models.TwoFactor.Delete(user.Email, user.Role)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "two-factor authentication disabled"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// createTwoFactorTokenHandler is the second step of a login with two-factor
+// authentication: the token from the password step and a code are exchanged
+// for the real authentication and refresh tokens.
+func (app *application) createTwoFactorTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		TwoFactorToken string `json:"two_factor_token"`
+		Code           string `json:"code"`
+		RecoveryCode   string `json:"recovery_code"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	if data.ValidateTokenPlaintext(v, input.TwoFactorToken); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	t, err := app.models.Tokens.GetUserForToken(data.ScopeTwoFactor, input.TwoFactorToken)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.invalidAuthenticationTokenResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if time.Now().After(t.Expiry) {
+		app.invalidAuthenticationTokenResponse(w, r)
+		return
+	}
+
+	attempt := app.checkSecondFactor(w, r, t.Email, t.Role, input.Code, input.RecoveryCode)
+	if attempt == nil {
+		return
+	}
+
+	err = app.models.Tokens.DeleteAllForUser(data.ScopeTwoFactor, t.Email, t.Role)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.loginSucceeded(r, attempt, t.Role)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	access, refresh, err := app.models.Tokens.NewPair(app.config.tokens.accessTTL, app.config.tokens.refreshTTL, t.Email, t.Role, r.UserAgent(), clientIP(r))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"authentication_token": access, "refresh_token": refresh}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// checkSecondFactor accepts either a TOTP code or an unused recovery code for
+// the account, and answers the request itself when neither is valid. Codes
+// are checked as login attempts, so they can't be guessed any faster than
+// passwords; the attempt is returned for the caller to settle once the code
+// has been accepted.
+func (app *application) checkSecondFactor(w http.ResponseWriter, r *http.Request, email, role, code, recoveryCode string) *loginAttempt {
+	v := validator.New()
+
+	v.Check(code != "" || recoveryCode != "", "code", "must be provided, or a recovery_code instead")
+	v.Check(code == "" || recoveryCode == "", "recovery_code", "must not be sent together with code")
+
+	if code != "" {
+		data.ValidateTOTPCode(v, code)
+	}
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return nil
+	}
+
+	attempt := app.beginLoginAttempt(w, r, email)
+	if attempt == nil {
+		return nil
+	}
+
+	tf, err := app.models.TwoFactor.Get(email, role)
+	if err != nil && !errors.Is(err, data.ErrRecordNotFound) {
+		app.serverErrorResponse(w, r, err)
+		return nil
+	}
+
+	var ok bool
+
+	switch {
+	case tf == nil || tf.ConfirmedAt == nil:
+		ok = false
+
+	case code != "":
+		var step int64
+
+		step, ok = totp.Validate(tf.Secret, code, time.Now(), tf.LastUsedStep)
+		if ok {
+			ok, err = app.models.TwoFactor.UseStep(email, role, step)
+		}
+
+	default:
+		ok, err = app.models.TwoFactor.UseRecoveryCode(email, role, strings.TrimSpace(recoveryCode))
+	}
+
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return nil
+	}
+
+	if !ok {
+		err = app.loginFailed(r, attempt, data.SecurityTwoFactorFailed, role)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return nil
+		}
+
+		app.invalidTwoFactorCodeResponse(w, r)
+		return nil
+	}
+
+	return attempt
+}
+
+// twoFactorSatisfied reports whether user may go on: either their role
+// doesn't require two-factor authentication or they have enabled it.
+func (app *application) twoFactorSatisfied(user *data.User) (bool, error) {
+	required, err := app.models.TwoFactor.RequiredForRole(user.Role)
+	if err != nil {
+		return false, err
+	}
+
+	if !required {
+		return true, nil
+	}
+
+	return app.models.TwoFactor.Enabled(user.Email, user.Role)
+}
+
+func (app *application) showRoleTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	role, ok := app.readRoleParam(w, r)
+	if !ok {
+		return
+	}
+
+	required, err := app.models.TwoFactor.RequiredForRole(role)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"role": role, "two_factor_required": required}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// updateRoleTwoFactorHandler lets an admin require two-factor authentication
+// for everyone with a role. Users who haven't enabled it yet can still sign in
+// and enroll, but every other endpoint is closed to them until they do.
+func (app *application) updateRoleTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	role, ok := app.readRoleParam(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Required *bool `json:"required"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	if v.Check(input.Required != nil, "required", "must be provided"); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.TwoFactor.SetRequiredForRole(role, *input.Required, app.contextGetUser(r).Email)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"role": role, "two_factor_required": *input.Required}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/0xMishra/makerble/internal/data"
+	"github.com/0xMishra/makerble/internal/validator"
+)
+
+func (app *application) activateUserHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		TokenPlaintext string `json:"token"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	if data.ValidateTokenPlaintext(v, input.TokenPlaintext); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	t, err := app.models.Tokens.GetUserForToken(data.ScopeActivation, input.TokenPlaintext)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("token", "invalid or expired activation token")
+			app.failedValidationResponse(w, r, v.Errors)
+
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if time.Now().After(t.Expiry) {
+		v.AddError("token", "invalid or expired activation token")
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	var user any
+
+	switch t.Role {
+	case "doctor":
+		var d *data.Doctor
+		d, err = app.models.Doctors.GetByEmail(t.Email)
+		if err == nil {
+			d.Activated = true
+			err = app.models.Doctors.Update(d)
+		}
+		user = d
+
+	case "receptionist":
+		var rec *data.Receptionist
+		rec, err = app.models.Receptionists.GetByEmail(t.Email)
+		if err == nil {
+			rec.Activated = true
+			err = app.models.Receptionists.Update(rec)
+		}
+		user = rec
+
+	case "admin":
+		var a *data.Admin
+		a, err = app.models.Admins.GetByEmail(t.Email)
+		if err == nil {
+			a.Activated = true
+			err = app.models.Admins.Update(a)
+		}
+		user = a
+
+	default:
+		err = fmt.Errorf("unknown role %q", t.Role)
+	}
+
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("token", "invalid or expired activation token")
+			app.failedValidationResponse(w, r, v.Errors)
+
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.models.Tokens.DeleteAllForUser(data.ScopeActivation, t.Email, t.Role)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"user": user}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// getUser loads the account registered under email for the given role. Only
+// doctors, receptionists and admins have accounts, so for any other role the
+// lookup fails closed with ErrRecordNotFound rather than an error.
+func (app *application) getUser(role, email string) (*data.User, error) {
+	switch role {
+	case "doctor":
+		d, err := app.models.Doctors.GetByEmail(email)
+		if err != nil {
+			return nil, err
+		}
+
+		user := &data.User{
+			ID:        d.ID,
+			Name:      d.Name,
+			Email:     d.Email,
+			Role:      role,
+			Activated: d.Activated,
+		}
+		return user, nil
+
+	case "receptionist":
+		rec, err := app.models.Receptionists.GetByEmail(email)
+		if err != nil {
+			return nil, err
+		}
+
+		user := &data.User{
+			ID:        rec.ID,
+			Name:      rec.Name,
+			Email:     rec.Email,
+			Role:      role,
+			Activated: rec.Activated,
+		}
+		return user, nil
+
+	case "admin":
+		a, err := app.models.Admins.GetByEmail(email)
+		if err != nil {
+			return nil, err
+		}
+
+		user := &data.User{
+			ID:        a.ID,
+			Name:      a.Name,
+			Email:     a.Email,
+			Role:      role,
+			Activated: a.Activated,
+		}
+		return user, nil
+	}
+
+	return nil, data.ErrRecordNotFound
+}
+
+// updateUserPasswordHandler sets a new password with a token from
+// POST /v1/tokens/password-reset. The token is consumed together with the
+// password change, and every session of the account is signed out.
+func (app *application) updateUserPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Password       string `json:"password"`
+		TokenPlaintext string `json:"token"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	validator.ValidatePlaintextPassword(v, input.Password)
+	data.ValidateTokenPlaintext(v, input.TokenPlaintext)
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	var password validator.Password
+
+	err = password.Set(input.Password)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	_, err = app.models.Tokens.ResetPassword(input.TokenPlaintext, password.Hash)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("token", "invalid or expired password reset token")
+			app.failedValidationResponse(w, r, v.Errors)
+
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "your password was successfully reset"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/0xMishra/makerble/internal/data"
+	"github.com/0xMishra/makerble/internal/mailer"
+)
+
+// testDSNEnv names the environment variable holding the DSN of a migrated,
+// disposable database. Tests that need one are skipped when it isn't set.
+const testDSNEnv = "MAKERBLE_TEST_DB_DSN"
+
+// newTestApplication returns an application whose emails are kept in the
+// returned transport. Its models have no database unless one is set.
+func newTestApplication(t *testing.T) (*application, *mailer.MemoryTransport) {
+	t.Helper()
+
+	transport := mailer.NewMemoryTransport()
+
+	app := &application{
+		logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+		mailer: mailer.New(transport, "Makerble <no-reply@makerble.test>", 0, 0),
+	}
+
+	return app, transport
+}
+
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	var cfg config
+
+	cfg.db.dsn = os.Getenv(testDSNEnv)
+	if cfg.db.dsn == "" {
+		t.Skipf("%s is not set", testDSNEnv)
+	}
+
+	cfg.db.maxOpenConns = 5
+	cfg.db.maxIdleConns = 5
+	cfg.db.maxIdleTime = time.Minute
+
+	db, err := openDB(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { db.Close() })
+
+	return db
+}
+
+// send runs a request with a JSON body through the application's routes.
+func send(t *testing.T, app *application, method, path string, header http.Header, body any) *httptest.ResponseRecorder {
+	t.Helper()
+
+	js, err := json.Marshal(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := httptest.NewRequest(method, path, strings.NewReader(string(js)))
+	for key, values := range header {
+		r.Header[key] = values
+	}
+
+	w := httptest.NewRecorder()
+	app.routes().ServeHTTP(w, r)
+
+	return w
+}
+
+func TestRegisterWithoutInvitation(t *testing.T) {
+	app, transport := newTestApplication(t)
+
+	body := map[string]any{
+		"name":           "Jane Doe",
+		"email":          "jane@example.com",
+		"password":       "pa55word1234",
+		"specialization": "cardiology",
+		"contact":        9876543210,
+	}
+
+	w := send(t, app, http.MethodPost, "/v1/register", http.Header{"Role": {"doctor"}}, body)
+
+	if w.Code != http.StatusForbidden {
+		t.Errorf("status = %d; want %d", w.Code, http.StatusForbidden)
+	}
+
+	app.wg.Wait()
+
+	if n := len(transport.Messages()); n != 0 {
+		t.Errorf("sent %d emails; want none", n)
+	}
+}
+
+func TestActivateUserInvalidToken(t *testing.T) {
+	app, _ := newTestApplication(t)
+
+	for _, token := range []string{"", "too-short", strings.Repeat("A", 27)} {
+		w := send(t, app, http.MethodPut, "/v1/users/activated", nil, map[string]string{"token": token})
+
+		if w.Code != http.StatusUnprocessableEntity {
+			t.Errorf("token %q: status = %d; want %d", token, w.Code, http.StatusUnprocessableEntity)
+		}
+	}
+}
+
+var activationTokenRX = regexp.MustCompile(`\{"token": "([A-Z0-9]+)"\}`)
+
+func TestRegisterAndActivate(t *testing.T) {
+	db := openTestDB(t)
+
+	app, transport := newTestApplication(t)
+	app.models = data.NewModels(db)
+
+	email := fmt.Sprintf("doctor-%d@example.com", time.Now().UnixNano())
+
+	t.Cleanup(func() {
+		db.Exec(`DELETE FROM shifts WHERE staff_role = 'doctor' AND staff_id IN (SELECT id FROM doctors WHERE email = $1)`, email)
+		db.Exec(`DELETE FROM doctors WHERE email = $1`, email)
+		db.Exec(`DELETE FROM tokens WHERE email = $1`, email)
+	})
+
+	invitation, err := app.models.Tokens.New(time.Hour, email, "doctor", data.ScopeInvitation)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := map[string]any{
+		"name":             "Jane Doe",
+		"email":            email,
+		"password":         "pa55word1234",
+		"specialization":   "cardiology",
+		"contact":          9876543210,
+		"shift_start":      "09:00",
+		"shift_end":        "17:00",
+		"invitation_token": invitation.Plaintext,
+	}
+
+	w := send(t, app, http.MethodPost, "/v1/register", http.Header{"Role": {"doctor"}}, body)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("register: status = %d; want %d: %s", w.Code, http.StatusAccepted, w.Body)
+	}
+
+	var registered struct {
+		Doctor struct {
+			Email     string
+			Activated bool
+		}
+	}
+
+	err = json.Unmarshal(w.Body.Bytes(), &registered)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if registered.Doctor.Email != email || registered.Doctor.Activated {
+		t.Errorf("registered doctor = %+v; want an inactive account for %s", registered.Doctor, email)
+	}
+
+	// The welcome email is sent in the background.
+	app.wg.Wait()
+
+	messages := transport.Messages()
+	if len(messages) != 1 {
+		t.Fatalf("sent %d emails; want 1", len(messages))
+	}
+
+	welcome := messages[0]
+
+	if welcome.Recipient != email || welcome.Subject != "Activate your Makerble account" {
+		t.Errorf("email to %q with subject %q; want the welcome email to %q", welcome.Recipient, welcome.Subject, email)
+	}
+
+	match := activationTokenRX.FindStringSubmatch(welcome.PlainBody)
+	if match == nil {
+		t.Fatalf("welcome email has no activation token:\n%s", welcome.PlainBody)
+	}
+
+	w = send(t, app, http.MethodPut, "/v1/users/activated", nil, map[string]string{"token": match[1]})
+	if w.Code != http.StatusOK {
+		t.Fatalf("activate: status = %d; want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+
+	d, err := app.models.Doctors.GetByEmail(email)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !d.Activated {
+		t.Error("doctor is still inactive after activation")
+	}
+
+	// Activation tokens are single use.
+	w = send(t, app, http.MethodPut, "/v1/users/activated", nil, map[string]string{"token": match[1]})
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("second activation: status = %d; want %d", w.Code, http.StatusUnprocessableEntity)
+	}
+
+	// The invitation is used up by the registration.
+	w = send(t, app, http.MethodPost, "/v1/register", http.Header{"Role": {"doctor"}}, body)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("second registration: status = %d; want %d", w.Code, http.StatusForbidden)
+	}
+}
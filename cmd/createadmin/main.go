@@ -0,0 +1,82 @@
+// Command createadmin creates an activated admin account, so that the first
+// administrator can sign in and invite everybody else.
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/0xMishra/makerble/internal/data"
+	"github.com/0xMishra/makerble/internal/validator"
+	"github.com/joho/godotenv"
+	_ "github.com/lib/pq"
+)
+
+func main() {
+	err := godotenv.Load()
+	if err != nil {
+		log.Fatal("Error loading .env file")
+	}
+
+	var (
+		dsn      string
+		name     string
+		email    string
+		password string
+	)
+
+	flag.StringVar(&dsn, "db-dsn", os.Getenv("POSTGRES_URL"), "PostgreSQL DSN")
+	flag.StringVar(&name, "name", "", "Admin name")
+	flag.StringVar(&email, "email", "", "Admin email")
+	flag.StringVar(&password, "password", "", "Admin password")
+
+	flag.Parse()
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err = db.PingContext(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	a := &data.Admin{
+		Name:      name,
+		Email:     email,
+		Activated: true,
+	}
+
+	err = a.Password.Set(password)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	v := validator.New()
+
+	if data.ValidateAdmin(v, a); !v.Valid() {
+		for key, message := range v.Errors {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", key, message)
+		}
+		os.Exit(1)
+	}
+
+	models := data.NewModels(db)
+
+	err = models.Admins.Insert(a)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Printf("created admin %d (%s)\n", a.ID, a.Email)
+}
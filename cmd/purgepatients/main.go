@@ -0,0 +1,72 @@
+// Command purgepatients permanently removes patients that were deleted more
+// than the retention period ago. It is meant to be run on a schedule. Every
+// purged record is noted in the audit trail, which itself is never purged.
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/0xMishra/makerble/internal/data"
+	"github.com/joho/godotenv"
+	_ "github.com/lib/pq"
+)
+
+func main() {
+	err := godotenv.Load()
+	if err != nil {
+		log.Fatal("Error loading .env file")
+	}
+
+	var (
+		dsn       string
+		retention time.Duration
+		timeout   time.Duration
+	)
+
+	flag.StringVar(&dsn, "db-dsn", os.Getenv("POSTGRES_URL"), "PostgreSQL DSN")
+	flag.DurationVar(&retention, "retention", 7*365*24*time.Hour, "How long deleted patients are kept before they are purged")
+	flag.DurationVar(&timeout, "timeout", 5*time.Minute, "Time allowed for the whole purge")
+
+	flag.Parse()
+
+	if retention <= 0 {
+		log.Fatal("retention must be positive")
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	err = db.PingContext(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	models := data.NewModels(db)
+
+	cutoff := time.Now().Add(-retention)
+
+	event := data.AuditEvent{
+		ActorEmail: "purgepatients",
+		ActorRole:  "system",
+		Action:     data.AuditPurge,
+	}
+
+	ids, err := models.Patients.Purge(ctx, cutoff, event)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Printf("purged %d patients deleted before %s\n", len(ids), cutoff.Format(time.RFC3339))
+}
@@ -0,0 +1,130 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/0xMishra/makerble/internal/validator"
+)
+
+type AdminModel struct {
+	DB *sql.DB
+}
+
+type Admin struct {
+	ID        int64              `json:"id"`
+	CreatedAt time.Time          `json:"created_at"`
+	Name      string             `json:"name"`
+	Email     string             `json:"email"`
+	Password  validator.Password `json:"-"`
+	Version   int64              `json:"-"`
+	Activated bool               `json:"activated"`
+}
+
+func ValidateAdmin(v *validator.Validator, a *Admin) {
+	v.Check(a.Name != "", "name", "must be provided")
+	v.Check(len(a.Name) <= 500, "name", "name must be at most 500 bytes long")
+
+	validator.ValidateEmail(v, a.Email)
+
+	if a.Password.Plaintext != nil {
+		validator.ValidatePlaintextPassword(v, *a.Password.Plaintext)
+	}
+
+	if a.Password.Hash == nil {
+		panic("missing password hash for user")
+	}
+}
+
+func (m AdminModel) Insert(a *Admin) error {
+	query := `
+		INSERT INTO admins (name, email, password_hash, activated)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at, version
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	args := []any{a.Name, a.Email, a.Password.Hash, a.Activated}
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&a.ID, &a.CreatedAt, &a.Version)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: duplicate key value violates unique constraint "admins_email_key"`:
+			return ErrDuplicateEmail
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m AdminModel) GetByEmail(email string) (*Admin, error) {
+	query := `
+		SELECT id, created_at, name, email, password_hash, version, activated
+		FROM admins
+		WHERE email = $1
+	`
+
+	var a Admin
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, email).Scan(
+		&a.ID,
+		&a.CreatedAt,
+		&a.Name,
+		&a.Email,
+		&a.Password.Hash,
+		&a.Version,
+		&a.Activated,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &a, nil
+}
+
+func (m AdminModel) Update(a *Admin) error {
+	query := `
+		UPDATE admins
+		SET name = $1, email = $2, password_hash = $3, activated = $4, version = version + 1
+		WHERE id = $5
+		RETURNING version
+	`
+
+	args := []any{
+		a.Name,
+		a.Email,
+		a.Password.Hash,
+		a.Activated,
+		a.ID,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&a.Version)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: duplicate key value violates unique constraint "admins_email_key"`:
+			return ErrDuplicateEmail
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
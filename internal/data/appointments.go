@@ -0,0 +1,302 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/0xMishra/makerble/internal/validator"
+	"github.com/lib/pq"
+)
+
+const (
+	AppointmentBooked    = "booked"
+	AppointmentCancelled = "cancelled"
+	AppointmentCompleted = "completed"
+	AppointmentNoShow    = "no_show"
+)
+
+var ErrAppointmentOverlap = errors.New("appointment overlaps another booking")
+
+type AppointmentModel struct {
+	DB *sql.DB
+}
+
+type Appointment struct {
+	ID        int64     `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	PatientID int64     `json:"patient_id"`
+	DoctorID  int64     `json:"doctor_id"`
+	StartsAt  time.Time `json:"starts_at"`
+	EndsAt    time.Time `json:"ends_at"`
+	Status    string    `json:"status"`
+	Reason    string    `json:"reason"`
+	Version   int64     `json:"version"`
+}
+
+func ValidateAppointment(v *validator.Validator, a *Appointment) {
+	v.Check(a.PatientID > 0, "patient_id", "must be provided")
+	v.Check(a.DoctorID > 0, "doctor_id", "must be provided")
+
+	v.Check(!a.StartsAt.IsZero(), "starts_at", "must be provided")
+	v.Check(!a.EndsAt.IsZero(), "ends_at", "must be provided")
+	v.Check(a.EndsAt.After(a.StartsAt), "ends_at", "must be after starts_at")
+	v.Check(a.EndsAt.Sub(a.StartsAt) <= 8*time.Hour, "ends_at", "appointment must not be longer than 8 hours")
+
+	v.Check(validator.PermittedValue(a.Status, AppointmentBooked, AppointmentCancelled, AppointmentCompleted, AppointmentNoShow), "status", "must be booked, cancelled, completed or no_show")
+	v.Check(len(a.Reason) <= 1000, "reason", "must not be more than 1000 bytes long")
+}
+
+// AppointmentFilters narrows down the appointments returned by GetAll. Zero
+// values leave the corresponding condition out of the query.
+type AppointmentFilters struct {
+	DoctorID  int64
+	PatientID int64
+	Status    string
+	From      time.Time
+	To        time.Time
+}
+
+func (m AppointmentModel) Insert(a *Appointment) error {
+	query := `
+		INSERT INTO appointments (patient_id, doctor_id, starts_at, ends_at, status, reason)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at, version
+	`
+
+	args := []any{a.PatientID, a.DoctorID, a.StartsAt, a.EndsAt, a.Status, a.Reason}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&a.ID, &a.CreatedAt, &a.Version)
+	if err != nil {
+		return appointmentError(err)
+	}
+
+	return nil
+}
+
+func (m AppointmentModel) GetByID(id int64) (*Appointment, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT id, created_at, patient_id, doctor_id, starts_at, ends_at, status, reason, version
+		FROM appointments
+		WHERE id = $1
+	`
+
+	var a Appointment
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+		&a.ID,
+		&a.CreatedAt,
+		&a.PatientID,
+		&a.DoctorID,
+		&a.StartsAt,
+		&a.EndsAt,
+		&a.Status,
+		&a.Reason,
+		&a.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+
+		default:
+			return nil, err
+		}
+	}
+
+	return &a, nil
+}
+
+func (m AppointmentModel) GetAll(af AppointmentFilters, filters Filters) ([]*Appointment, Metadata, error) {
+	query := fmt.Sprintf(`
+		SELECT count(*) OVER(), id, created_at, patient_id, doctor_id, starts_at, ends_at, status, reason, version
+		FROM appointments
+		WHERE (doctor_id = $1 OR $1 = 0)
+		AND (patient_id = $2 OR $2 = 0)
+		AND (status = $3 OR $3 = '')
+		AND ($4::timestamptz IS NULL OR ends_at > $4)
+		AND ($5::timestamptz IS NULL OR starts_at < $5)
+		ORDER BY %s %s, id ASC
+		LIMIT $6 OFFSET $7
+	`, filters.sortColumn(), filters.sortDirection())
+
+	args := []any{
+		af.DoctorID,
+		af.PatientID,
+		af.Status,
+		sql.NullTime{Time: af.From, Valid: !af.From.IsZero()},
+		sql.NullTime{Time: af.To, Valid: !af.To.IsZero()},
+		filters.limit(),
+		filters.offset(),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	appointments := []*Appointment{}
+
+	for rows.Next() {
+		var a Appointment
+
+		err := rows.Scan(
+			&totalRecords,
+			&a.ID,
+			&a.CreatedAt,
+			&a.PatientID,
+			&a.DoctorID,
+			&a.StartsAt,
+			&a.EndsAt,
+			&a.Status,
+			&a.Reason,
+			&a.Version,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		appointments = append(appointments, &a)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return appointments, metadata, nil
+}
+
+func (m AppointmentModel) Update(a *Appointment) error {
+	query := `
+		UPDATE appointments
+		SET starts_at = $1, ends_at = $2, status = $3, reason = $4, version = version + 1
+		WHERE id = $5 AND version = $6
+		RETURNING version
+	`
+
+	args := []any{a.StartsAt, a.EndsAt, a.Status, a.Reason, a.ID, a.Version}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&a.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+
+		default:
+			return appointmentError(err)
+		}
+	}
+
+	return nil
+}
+
+// HasOverlap reports whether the doctor already has a booked appointment
+// intersecting [start, end), ignoring the appointment with excludeID.
+func (m AppointmentModel) HasOverlap(doctorID int64, start, end time.Time, excludeID int64) (bool, error) {
+	query := `
+		SELECT EXISTS (
+			SELECT 1 FROM appointments
+			WHERE doctor_id = $1
+			AND status = 'booked'
+			AND id <> $4
+			AND tstzrange(starts_at, ends_at) && tstzrange($2, $3)
+		)
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var exists bool
+
+	err := m.DB.QueryRowContext(ctx, query, doctorID, start, end, excludeID).Scan(&exists)
+
+	return exists, err
+}
+
+// appointmentError maps the constraint violations raised by the appointments
+// table to the errors exposed by this package.
+func appointmentError(err error) error {
+	switch {
+	case strings.Contains(err.Error(), `"appointments_no_overlap"`):
+		return ErrAppointmentOverlap
+
+	case strings.Contains(err.Error(), `violates foreign key constraint`):
+		return ErrRecordNotFound
+
+	default:
+		return err
+	}
+}
+
+// GetBookedForDoctors returns the booked appointments of the given doctors
+// that overlap [from, to).
+func (m AppointmentModel) GetBookedForDoctors(doctorIDs []int64, from, to time.Time) ([]*Appointment, error) {
+	query := `
+		SELECT id, created_at, patient_id, doctor_id, starts_at, ends_at, status, reason, version
+		FROM appointments
+		WHERE doctor_id = ANY($1)
+		AND status = 'booked'
+		AND tstzrange(starts_at, ends_at) && tstzrange($2, $3)
+		ORDER BY starts_at
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, pq.Array(doctorIDs), from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	appointments := []*Appointment{}
+
+	for rows.Next() {
+		var a Appointment
+
+		err := rows.Scan(
+			&a.ID,
+			&a.CreatedAt,
+			&a.PatientID,
+			&a.DoctorID,
+			&a.StartsAt,
+			&a.EndsAt,
+			&a.Status,
+			&a.Reason,
+			&a.Version,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		appointments = append(appointments, &a)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return appointments, nil
+}
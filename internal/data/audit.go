@@ -0,0 +1,351 @@
+package data
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	AuditView    = "view"
+	AuditList    = "list"
+	AuditSearch  = "search"
+	AuditCreate  = "create"
+	AuditUpdate  = "update"
+	AuditDelete  = "delete"
+	AuditShare   = "share"
+	AuditUnshare = "unshare"
+	AuditRevert  = "revert"
+	AuditRestore = "restore"
+	AuditPurge   = "purge"
+)
+
+// auditChainLock is the advisory lock key that serialises appends to the
+// audit chain, so that every event links to the one committed before it.
+const auditChainLock = 7_165_213_801
+
+// genesisHash is the previous hash of the first event in the chain.
+var genesisHash = make([]byte, sha256.Size)
+
+// AuditEvent records one access to or change of a patient record. Every
+// event carries the hash of the event before it, so removing or altering a
+// row breaks the chain from that point on.
+type AuditEvent struct {
+	ID         int64           `json:"id"`
+	CreatedAt  time.Time       `json:"created_at"`
+	ActorEmail string          `json:"actor_email"`
+	ActorRole  string          `json:"actor_role"`
+	Action     string          `json:"action"`
+	PatientID  int64           `json:"patient_id"`
+	RequestID  string          `json:"request_id"`
+	IP         string          `json:"ip"`
+	Changes    json.RawMessage `json:"changes,omitempty"`
+	PrevHash   []byte          `json:"prev_hash"`
+	Hash       []byte          `json:"hash"`
+}
+
+// computeHash hashes the previous hash together with a JSON encoding of the
+// event's fields. The ID is left out as it is only known after the insert.
+func (e *AuditEvent) computeHash() ([]byte, error) {
+	payload, err := json.Marshal(struct {
+		CreatedAt  string          `json:"created_at"`
+		ActorEmail string          `json:"actor_email"`
+		ActorRole  string          `json:"actor_role"`
+		Action     string          `json:"action"`
+		PatientID  int64           `json:"patient_id"`
+		RequestID  string          `json:"request_id"`
+		IP         string          `json:"ip"`
+		Changes    json.RawMessage `json:"changes"`
+	}{
+		CreatedAt:  e.CreatedAt.UTC().Format(time.RFC3339Nano),
+		ActorEmail: e.ActorEmail,
+		ActorRole:  e.ActorRole,
+		Action:     e.Action,
+		PatientID:  e.PatientID,
+		RequestID:  e.RequestID,
+		IP:         e.IP,
+		Changes:    e.Changes,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	h := sha256.New()
+	h.Write(e.PrevHash)
+	h.Write(payload)
+
+	return h.Sum(nil), nil
+}
+
+// FieldChange holds the JSON values of one field before and after a change.
+type FieldChange struct {
+	From json.RawMessage `json:"from"`
+	To   json.RawMessage `json:"to"`
+}
+
+// PatientChanges returns the fields that differ between two versions of a
+// patient as a JSON object of FieldChange values. A nil before or after
+// stands for a record that is being created or deleted.
+func PatientChanges(before, after *Patient) (json.RawMessage, error) {
+	fields := func(p *Patient) (map[string]json.RawMessage, error) {
+		m := map[string]json.RawMessage{}
+		if p == nil {
+			return m, nil
+		}
+
+		js, err := json.Marshal(p)
+		if err != nil {
+			return nil, err
+		}
+
+		err = json.Unmarshal(js, &m)
+		return m, err
+	}
+
+	old, err := fields(before)
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := fields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := map[string]FieldChange{}
+
+	for _, m := range []map[string]json.RawMessage{old, current} {
+		for key := range m {
+			if key == "version" || bytes.Equal(old[key], current[key]) {
+				continue
+			}
+
+			changes[key] = FieldChange{From: old[key], To: current[key]}
+		}
+	}
+
+	return json.Marshal(changes)
+}
+
+type AuditEventModel struct {
+	DB *sql.DB
+}
+
+// Insert appends the events to the chain in order, filling in their IDs,
+// timestamps and hashes.
+func (m AuditEventModel) Insert(events ...*AuditEvent) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = appendAuditEvents(ctx, tx, events...)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// appendAuditEvents links the events onto the end of the chain as part of tx,
+// so that a change and the event recording it are committed or rolled back
+// together. The chain stays locked until tx ends.
+func appendAuditEvents(ctx context.Context, tx *sql.Tx, events ...*AuditEvent) error {
+	_, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, auditChainLock)
+	if err != nil {
+		return err
+	}
+
+	var prev []byte
+
+	err = tx.QueryRowContext(ctx, `SELECT hash FROM audit_events ORDER BY id DESC LIMIT 1`).Scan(&prev)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			prev = genesisHash
+		default:
+			return err
+		}
+	}
+
+	query := `
+		INSERT INTO audit_events (created_at, actor_email, actor_role, action, patient_id, request_id, ip, changes, prev_hash, hash)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING id
+	`
+
+	// Postgres keeps microseconds, so anything finer would change the hash.
+	now := time.Now().Truncate(time.Microsecond)
+
+	for _, e := range events {
+		e.CreatedAt = now
+		e.PrevHash = prev
+
+		e.Hash, err = e.computeHash()
+		if err != nil {
+			return err
+		}
+
+		var changes any
+		if e.Changes != nil {
+			changes = string(e.Changes)
+		}
+
+		args := []any{e.CreatedAt, e.ActorEmail, e.ActorRole, e.Action, e.PatientID, e.RequestID, e.IP, changes, e.PrevHash, e.Hash}
+
+		err = tx.QueryRowContext(ctx, query, args...).Scan(&e.ID)
+		if err != nil {
+			return err
+		}
+
+		prev = e.Hash
+	}
+
+	return nil
+}
+
+func (m AuditEventModel) GetAllForPatient(patientID int64, filters Filters) ([]*AuditEvent, Metadata, error) {
+	query := fmt.Sprintf(`
+		SELECT count(*) OVER(), id, created_at, actor_email, actor_role, action, patient_id, request_id, ip, changes, prev_hash, hash
+		FROM audit_events
+		WHERE patient_id = $1
+		ORDER BY %s %s, id ASC
+		LIMIT $2 OFFSET $3
+	`, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, patientID, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	events := []*AuditEvent{}
+
+	for rows.Next() {
+		var e AuditEvent
+
+		err := rows.Scan(
+			&totalRecords,
+			&e.ID,
+			&e.CreatedAt,
+			&e.ActorEmail,
+			&e.ActorRole,
+			&e.Action,
+			&e.PatientID,
+			&e.RequestID,
+			&e.IP,
+			(*[]byte)(&e.Changes),
+			&e.PrevHash,
+			&e.Hash,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		events = append(events, &e)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return events, metadata, nil
+}
+
+// AuditVerification is the outcome of walking the audit chain.
+type AuditVerification struct {
+	Checked  int
+	LastHash []byte
+	// Broken is the first event whose link or hash doesn't match, if any.
+	Broken *AuditEvent
+}
+
+// Verify walks the whole chain in insertion order and recomputes every hash.
+// It stops at the first event that doesn't match. Rows cut off the end of the
+// chain can only be noticed by comparing LastHash with a value kept earlier.
+func (m AuditEventModel) Verify(ctx context.Context) (*AuditVerification, error) {
+	query := `
+		SELECT id, created_at, actor_email, actor_role, action, patient_id, request_id, ip, changes, prev_hash, hash
+		FROM audit_events
+		ORDER BY id
+	`
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := &AuditVerification{LastHash: genesisHash}
+
+	for rows.Next() {
+		var e AuditEvent
+
+		err := rows.Scan(
+			&e.ID,
+			&e.CreatedAt,
+			&e.ActorEmail,
+			&e.ActorRole,
+			&e.Action,
+			&e.PatientID,
+			&e.RequestID,
+			&e.IP,
+			(*[]byte)(&e.Changes),
+			&e.PrevHash,
+			&e.Hash,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		ok, err := result.check(&e)
+		if err != nil {
+			return nil, err
+		}
+
+		if !ok {
+			return result, nil
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// check links the next event of the chain onto the verification. When its
+// link or hash doesn't match, the event is recorded as Broken and false is
+// returned.
+func (v *AuditVerification) check(e *AuditEvent) (bool, error) {
+	hash, err := e.computeHash()
+	if err != nil {
+		return false, err
+	}
+
+	if !bytes.Equal(e.PrevHash, v.LastHash) || !bytes.Equal(e.Hash, hash) {
+		v.Broken = e
+		return false, nil
+	}
+
+	v.Checked++
+	v.LastHash = e.Hash
+
+	return true, nil
+}
@@ -0,0 +1,160 @@
+package data
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// auditChain builds a chain of n events the way Insert links them.
+func auditChain(t *testing.T, n int) []*AuditEvent {
+	t.Helper()
+
+	created := time.Date(2024, time.March, 1, 9, 0, 0, 0, time.UTC)
+	prev := genesisHash
+
+	events := make([]*AuditEvent, n)
+
+	for i := range events {
+		e := &AuditEvent{
+			ID:         int64(i + 1),
+			CreatedAt:  created.Add(time.Duration(i) * time.Minute),
+			ActorEmail: "doctor@example.com",
+			ActorRole:  "doctor",
+			Action:     AuditUpdate,
+			PatientID:  42,
+			RequestID:  "req",
+			IP:         "192.0.2.1",
+			Changes:    json.RawMessage(`{"diagnosis":{"from":"flu","to":"cold"}}`),
+			PrevHash:   prev,
+		}
+
+		var err error
+
+		e.Hash, err = e.computeHash()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		events[i] = e
+		prev = e.Hash
+	}
+
+	return events
+}
+
+// verifyChain runs the events through the same checks as Verify.
+func verifyChain(t *testing.T, events []*AuditEvent) *AuditVerification {
+	t.Helper()
+
+	result := &AuditVerification{LastHash: genesisHash}
+
+	for _, e := range events {
+		ok, err := result.check(e)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !ok {
+			break
+		}
+	}
+
+	return result
+}
+
+func TestAuditChainIntact(t *testing.T) {
+	events := auditChain(t, 5)
+
+	result := verifyChain(t, events)
+
+	if result.Broken != nil {
+		t.Fatalf("Broken = event %d; want an intact chain", result.Broken.ID)
+	}
+
+	if result.Checked != len(events) {
+		t.Errorf("Checked = %d; want %d", result.Checked, len(events))
+	}
+
+	if !bytes.Equal(result.LastHash, events[len(events)-1].Hash) {
+		t.Errorf("LastHash is not the hash of the last event")
+	}
+}
+
+func TestAuditChainTampering(t *testing.T) {
+	tests := []struct {
+		name string
+		// tamper alters the chain and returns the ID of the first event that
+		// should be reported as broken.
+		tamper func(events []*AuditEvent) ([]*AuditEvent, int64)
+	}{
+		{
+			"altered action",
+			func(events []*AuditEvent) ([]*AuditEvent, int64) {
+				events[2].Action = AuditView
+				return events, 3
+			},
+		},
+		{
+			"altered changes",
+			func(events []*AuditEvent) ([]*AuditEvent, int64) {
+				events[1].Changes = json.RawMessage(`{"diagnosis":{"from":"flu","to":"fine"}}`)
+				return events, 2
+			},
+		},
+		{
+			"altered timestamp",
+			func(events []*AuditEvent) ([]*AuditEvent, int64) {
+				events[3].CreatedAt = events[3].CreatedAt.Add(time.Microsecond)
+				return events, 4
+			},
+		},
+		{
+			"rehashed event",
+			func(events []*AuditEvent) ([]*AuditEvent, int64) {
+				// Fixing up the altered event's own hash still breaks the
+				// link to the event after it.
+				events[1].ActorEmail = "someone@example.com"
+				events[1].Hash, _ = events[1].computeHash()
+				return events, 3
+			},
+		},
+		{
+			"deleted event",
+			func(events []*AuditEvent) ([]*AuditEvent, int64) {
+				return append(events[:2], events[3:]...), 4
+			},
+		},
+		{
+			"reordered events",
+			func(events []*AuditEvent) ([]*AuditEvent, int64) {
+				events[1], events[2] = events[2], events[1]
+				return events, 3
+			},
+		},
+		{
+			"replaced genesis link",
+			func(events []*AuditEvent) ([]*AuditEvent, int64) {
+				events[0].PrevHash = events[4].Hash
+				return events, 1
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, wantBroken := tt.tamper(auditChain(t, 5))
+
+			result := verifyChain(t, events)
+
+			if result.Broken == nil {
+				t.Fatal("Broken = nil; want the tampering to be detected")
+			}
+
+			if result.Broken.ID != wantBroken {
+				t.Errorf("Broken = event %d; want event %d", result.Broken.ID, wantBroken)
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package data
+
+import (
+	"time"
+)
+
+// Slot is a free period in which an appointment can be booked.
+type Slot struct {
+	StartsAt time.Time `json:"starts_at"`
+	EndsAt   time.Time `json:"ends_at"`
+}
+
+type interval struct {
+	start, end time.Time
+}
+
+// FreeSlots splits the doctor's shifts between from and to into consecutive
+// slots of the given duration. Approved leave and holidays, recurring breaks
+// and booked appointments are taken out first. Shift and break times are read as
+// wall-clock times in the location of from, and a slot may run across
+// midnight when the shift does.
+func FreeSlots(d *Doctor, from, to time.Time, duration time.Duration, breaks []*DoctorBreak, exceptions []*ScheduleException, booked []*Appointment) []Slot {
+	slots := []Slot{}
+
+	if duration <= 0 {
+		return slots
+	}
+
+	free := d.Shifts.Intervals(from, to)
+
+	for _, e := range exceptions {
+		if e.Status == ExceptionApproved && e.Blocks() {
+			free = subtract(free, interval{e.StartsAt, e.EndsAt})
+		}
+	}
+
+	// Start a day early so that breaks also cut into a shift carried over
+	// from the night before from.
+	first := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, from.Location()).AddDate(0, 0, -1)
+
+	for day := first; day.Before(to); day = day.AddDate(0, 0, 1) {
+		for _, b := range breaks {
+			if b.Weekday != nil && *b.Weekday != int(day.Weekday()) {
+				continue
+			}
+
+			start, err := ParseClockTime(b.StartTime)
+			if err != nil {
+				continue
+			}
+
+			end, err := ParseClockTime(b.EndTime)
+			if err != nil {
+				continue
+			}
+
+			// Like an overnight shift, a break ending before it starts
+			// finishes on the following day.
+			until := end.on(day)
+			if end <= start {
+				until = end.on(day.AddDate(0, 0, 1))
+			}
+
+			free = subtract(free, interval{start.on(day), until})
+		}
+	}
+
+	for _, a := range booked {
+		free = subtract(free, interval{a.StartsAt, a.EndsAt})
+	}
+
+	for _, iv := range free {
+		for s := iv.start; !s.Add(duration).After(iv.end); s = s.Add(duration) {
+			slots = append(slots, Slot{StartsAt: s, EndsAt: s.Add(duration)})
+		}
+	}
+
+	return slots
+}
+
+// subtract removes cut from every interval in ivs, splitting an interval in
+// two when cut falls strictly inside it.
+func subtract(ivs []interval, cut interval) []interval {
+	if !cut.end.After(cut.start) {
+		return ivs
+	}
+
+	result := make([]interval, 0, len(ivs))
+
+	for _, iv := range ivs {
+		if !cut.start.Before(iv.end) || !cut.end.After(iv.start) {
+			result = append(result, iv)
+			continue
+		}
+
+		if cut.start.After(iv.start) {
+			result = append(result, interval{iv.start, cut.start})
+		}
+
+		if cut.end.Before(iv.end) {
+			result = append(result, interval{cut.end, iv.end})
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,190 @@
+package data
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSubtract(t *testing.T) {
+	day := []interval{{at(4, "09:00"), at(4, "17:00")}}
+	split := []interval{{at(4, "09:00"), at(4, "12:00")}, {at(4, "13:00"), at(4, "17:00")}}
+
+	tests := []struct {
+		name string
+		ivs  []interval
+		cut  interval
+		want []interval
+	}{
+		{"before", day, interval{at(4, "07:00"), at(4, "08:00")}, day},
+		{"touching the start", day, interval{at(4, "08:00"), at(4, "09:00")}, day},
+		{"touching the end", day, interval{at(4, "17:00"), at(4, "18:00")}, day},
+		{"over the start", day, interval{at(4, "08:00"), at(4, "10:00")}, []interval{{at(4, "10:00"), at(4, "17:00")}}},
+		{"over the end", day, interval{at(4, "16:00"), at(4, "18:00")}, []interval{{at(4, "09:00"), at(4, "16:00")}}},
+		{"inside", day, interval{at(4, "12:00"), at(4, "13:00")}, split},
+		{"whole interval", day, interval{at(4, "09:00"), at(4, "17:00")}, []interval{}},
+		{"around the interval", day, interval{at(4, "08:00"), at(4, "18:00")}, []interval{}},
+		{"empty cut", day, interval{at(4, "12:00"), at(4, "12:00")}, day},
+		{"reversed cut", day, interval{at(4, "13:00"), at(4, "12:00")}, day},
+		{
+			"across two intervals",
+			split,
+			interval{at(4, "11:00"), at(4, "14:00")},
+			[]interval{{at(4, "09:00"), at(4, "11:00")}, {at(4, "14:00"), at(4, "17:00")}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := subtract(tt.ivs, tt.cut)
+
+			if !equalIntervals(got, tt.want) {
+				t.Errorf("subtract = %v; want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFreeSlots(t *testing.T) {
+	monday := 1
+
+	morning := &Doctor{Shifts: Schedule{shift(1, "09:00", "12:00")}}
+
+	tests := []struct {
+		name       string
+		doctor     *Doctor
+		from, to   time.Time
+		duration   time.Duration
+		breaks     []*DoctorBreak
+		exceptions []*ScheduleException
+		booked     []*Appointment
+		want       []time.Time
+	}{
+		{
+			name:     "whole shift",
+			doctor:   morning,
+			from:     at(4, "00:00"),
+			to:       at(5, "00:00"),
+			duration: time.Hour,
+			want:     []time.Time{at(4, "09:00"), at(4, "10:00"), at(4, "11:00")},
+		},
+		{
+			name:     "leftover shorter than a slot",
+			doctor:   &Doctor{Shifts: Schedule{shift(1, "09:00", "11:30")}},
+			from:     at(4, "00:00"),
+			to:       at(5, "00:00"),
+			duration: time.Hour,
+			want:     []time.Time{at(4, "09:00"), at(4, "10:00")},
+		},
+		{
+			name:     "weekly break",
+			doctor:   morning,
+			from:     at(4, "00:00"),
+			to:       at(5, "00:00"),
+			duration: time.Hour,
+			breaks:   []*DoctorBreak{{Weekday: &monday, StartTime: "10:00", EndTime: "10:30"}},
+			want:     []time.Time{at(4, "09:00"), at(4, "10:30")},
+		},
+		{
+			name:     "daily break",
+			doctor:   morning,
+			from:     at(4, "00:00"),
+			to:       at(5, "00:00"),
+			duration: 30 * time.Minute,
+			breaks:   []*DoctorBreak{{StartTime: "09:30", EndTime: "11:00"}},
+			want:     []time.Time{at(4, "09:00"), at(4, "11:00"), at(4, "11:30")},
+		},
+		{
+			name:     "approved leave",
+			doctor:   morning,
+			from:     at(4, "00:00"),
+			to:       at(5, "00:00"),
+			duration: time.Hour,
+			exceptions: []*ScheduleException{
+				{Kind: ExceptionLeave, Status: ExceptionApproved, StartsAt: at(4, "09:00"), EndsAt: at(4, "11:00")},
+			},
+			want: []time.Time{at(4, "11:00")},
+		},
+		{
+			name:     "holiday",
+			doctor:   morning,
+			from:     at(4, "00:00"),
+			to:       at(5, "00:00"),
+			duration: time.Hour,
+			exceptions: []*ScheduleException{
+				{Kind: ExceptionHoliday, Status: ExceptionApproved, StartsAt: at(4, "00:00"), EndsAt: at(5, "00:00")},
+			},
+			want: []time.Time{},
+		},
+		{
+			name:     "pending leave and on call ignored",
+			doctor:   morning,
+			from:     at(4, "00:00"),
+			to:       at(5, "00:00"),
+			duration: time.Hour,
+			exceptions: []*ScheduleException{
+				{Kind: ExceptionLeave, Status: ExceptionPending, StartsAt: at(4, "09:00"), EndsAt: at(4, "12:00")},
+				{Kind: ExceptionOnCall, Status: ExceptionApproved, StartsAt: at(4, "09:00"), EndsAt: at(4, "12:00")},
+			},
+			want: []time.Time{at(4, "09:00"), at(4, "10:00"), at(4, "11:00")},
+		},
+		{
+			name:     "booked appointment",
+			doctor:   morning,
+			from:     at(4, "00:00"),
+			to:       at(5, "00:00"),
+			duration: time.Hour,
+			booked:   []*Appointment{{StartsAt: at(4, "10:00"), EndsAt: at(4, "11:00")}},
+			want:     []time.Time{at(4, "09:00"), at(4, "11:00")},
+		},
+		{
+			name:     "across midnight",
+			doctor:   &Doctor{Shifts: Schedule{shift(0, "22:30", "02:00")}},
+			from:     at(3, "00:00"),
+			to:       at(4, "12:00"),
+			duration: time.Hour,
+			want:     []time.Time{at(3, "22:30"), at(3, "23:30"), at(4, "00:30")},
+		},
+		{
+			name:     "break after midnight of a shift from the day before",
+			doctor:   &Doctor{Shifts: Schedule{shift(0, "22:00", "03:00")}},
+			from:     at(4, "00:00"),
+			to:       at(4, "12:00"),
+			duration: time.Hour,
+			breaks:   []*DoctorBreak{{Weekday: &monday, StartTime: "00:00", EndTime: "00:30"}},
+			want:     []time.Time{at(4, "00:30"), at(4, "01:30")},
+		},
+		{
+			name:     "break across midnight",
+			doctor:   &Doctor{Shifts: Schedule{shift(0, "22:00", "03:00")}},
+			from:     at(3, "00:00"),
+			to:       at(4, "12:00"),
+			duration: time.Hour,
+			breaks:   []*DoctorBreak{{StartTime: "23:30", EndTime: "00:30"}},
+			want:     []time.Time{at(3, "22:00"), at(4, "00:30"), at(4, "01:30")},
+		},
+		{
+			name:     "no duration",
+			doctor:   morning,
+			from:     at(4, "00:00"),
+			to:       at(5, "00:00"),
+			duration: 0,
+			want:     []time.Time{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			slots := FreeSlots(tt.doctor, tt.from, tt.to, tt.duration, tt.breaks, tt.exceptions, tt.booked)
+
+			if len(slots) != len(tt.want) {
+				t.Fatalf("FreeSlots = %v; want slots starting at %v", slots, tt.want)
+			}
+
+			for i, s := range slots {
+				if !s.StartsAt.Equal(tt.want[i]) || !s.EndsAt.Equal(tt.want[i].Add(tt.duration)) {
+					t.Errorf("slot %d = %v to %v; want %v to %v", i, s.StartsAt, s.EndsAt, tt.want[i], tt.want[i].Add(tt.duration))
+				}
+			}
+		})
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"math"
 	"time"
 
@@ -19,12 +20,12 @@ type Doctor struct {
 	CreatedAt      time.Time          `json:"created_at"`
 	Name           string             `json:"name"`
 	Email          string             `json:"email"`
-	Password       validator.Password `json:"password"`
+	Password       validator.Password `json:"-"`
 	Version        int64              `json:"version"`
 	Specialization string             `json:"specialization"`
 	Contact        int64              `json:"contact"`
-	ShiftStart     time.Time          `json:"shift_start"`
-	ShiftEnd       time.Time          `json:"shift_end"`
+	Shifts         Schedule           `json:"shifts"`
+	Activated      bool               `json:"activated"`
 }
 
 func ValidateDoctor(v *validator.Validator, d *Doctor) {
@@ -34,7 +35,7 @@ func ValidateDoctor(v *validator.Validator, d *Doctor) {
 	v.Check(math.Floor(math.Log10(math.Abs(float64(d.Contact))))+1 == 10, "contact", "doctor's contact number should be at least 10 digits long")
 
 	validator.ValidateEmail(v, d.Email)
-	validator.ValidateShift(v, d.ShiftStart, d.ShiftEnd)
+	ValidateSchedule(v, d.Shifts)
 
 	if d.Password.Plaintext != nil {
 		validator.ValidatePlaintextPassword(v, *d.Password.Plaintext)
@@ -45,12 +46,17 @@ func ValidateDoctor(v *validator.Validator, d *Doctor) {
 	}
 }
 
+// OnShift reports whether [start, end) falls within one of the doctor's
+// shifts. Shift times are read as wall-clock times in the zone of start.
+func (d *Doctor) OnShift(start, end time.Time) bool {
+	return d.Shifts.Covers(start, end)
+}
+
 func (m DoctorModel) Insert(d *Doctor) error {
 	query := `
-		INSERT INTO doctors (name, email, password_hash, specialization, contact, shift_start, shift_end)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
-		RETURNING id, created_at, version
-
+		INSERT INTO doctors (name, email, password_hash, specialization, contact)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at, version, activated
 	`
 	args := []any{
 		d.Name,
@@ -58,29 +64,38 @@ func (m DoctorModel) Insert(d *Doctor) error {
 		d.Password.Hash,
 		d.Specialization,
 		d.Contact,
-		d.ShiftStart,
-		d.ShiftEnd,
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&d.ID, &d.CreatedAt, &d.Version)
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&d.ID, &d.CreatedAt, &d.Version, &d.Activated)
 	if err != nil {
 		switch {
-		case err.Error() == `pq: duplicate key value violates unique constraint "users_email_key"`:
+		case err.Error() == `pq: duplicate key value violates unique constraint "doctors_email_key"`:
 			return ErrDuplicateEmail
 		default:
 			return err
 		}
 	}
 
-	return nil
+	err = replaceShifts(ctx, tx, "doctor", d.ID, d.Shifts)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
 func (m DoctorModel) GetByEmail(email string) (*Doctor, error) {
 	query := `
-		SELECT id, created_at, name, email, specialization, contact, shift_start, shift_end
+		SELECT id, created_at, name, email, password_hash, version, specialization, contact, activated, ` + shiftsColumn("doctor", "doctors") + `
 		FROM doctors
 		WHERE email = $1
 	`
@@ -94,10 +109,12 @@ func (m DoctorModel) GetByEmail(email string) (*Doctor, error) {
 		&d.CreatedAt,
 		&d.Name,
 		&d.Email,
+		&d.Password.Hash,
+		&d.Version,
 		&d.Specialization,
 		&d.Contact,
-		&d.ShiftStart,
-		&d.ShiftEnd,
+		&d.Activated,
+		&d.Shifts,
 	)
 	if err != nil {
 		switch {
@@ -109,14 +126,14 @@ func (m DoctorModel) GetByEmail(email string) (*Doctor, error) {
 		}
 	}
 
-	return nil, nil
+	return &d, nil
 }
 
 func (m DoctorModel) Update(d *Doctor) error {
 	query := `
 		UPDATE doctors
-		SET name = $1, email = $2, password_hash = $3, specialization = $4, contact = $5, shift_start = $6, shift_end = $7, version = version + 1
-		WHERE id = $8
+		SET name = $1, email = $2, password_hash = $3, specialization = $4, contact = $5, activated = $6, version = version + 1
+		WHERE id = $7
 		RETURNING version
 	`
 
@@ -126,17 +143,25 @@ func (m DoctorModel) Update(d *Doctor) error {
 		d.Password.Hash,
 		d.Specialization,
 		d.Contact,
-		d.ShiftStart,
-		d.ShiftEnd,
+		d.Activated,
 		d.ID,
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&d.Version)
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&d.Version)
 	if err != nil {
 		switch {
+		case err.Error() == `pq: duplicate key value violates unique constraint "doctors_email_key"`:
+			return ErrDuplicateEmail
+
 		case errors.Is(err, sql.ErrNoRows):
 			return ErrRecordNotFound
 
@@ -145,5 +170,108 @@ func (m DoctorModel) Update(d *Doctor) error {
 		}
 	}
 
-	return nil
+	err = replaceShifts(ctx, tx, "doctor", d.ID, d.Shifts)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (m DoctorModel) GetByID(id int64) (*Doctor, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT id, created_at, name, email, password_hash, version, specialization, contact, activated, ` + shiftsColumn("doctor", "doctors") + `
+		FROM doctors
+		WHERE id = $1
+	`
+
+	var d Doctor
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+		&d.ID,
+		&d.CreatedAt,
+		&d.Name,
+		&d.Email,
+		&d.Password.Hash,
+		&d.Version,
+		&d.Specialization,
+		&d.Contact,
+		&d.Activated,
+		&d.Shifts,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+
+		default:
+			return nil, err
+		}
+	}
+
+	return &d, nil
+}
+
+// GetAll lists the doctors matching name and specialization. With
+// activatedOnly set, doctors who haven't activated their account are left
+// out before paginating.
+func (m DoctorModel) GetAll(name, specialization string, activatedOnly bool, filters Filters) ([]*Doctor, Metadata, error) {
+	query := fmt.Sprintf(`
+		SELECT count(*) OVER(), id, created_at, name, email, password_hash, version, specialization, contact, activated, %s
+		FROM doctors
+		WHERE (name ILIKE '%%' || $1 || '%%' OR $1 = '')
+		AND (specialization ILIKE $2 OR $2 = '')
+		AND (activated OR NOT $5)
+		ORDER BY %s %s, id ASC
+		LIMIT $3 OFFSET $4
+	`, shiftsColumn("doctor", "doctors"), filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, name, specialization, filters.limit(), filters.offset(), activatedOnly)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	doctors := []*Doctor{}
+
+	for rows.Next() {
+		var d Doctor
+
+		err := rows.Scan(
+			&totalRecords,
+			&d.ID,
+			&d.CreatedAt,
+			&d.Name,
+			&d.Email,
+			&d.Password.Hash,
+			&d.Version,
+			&d.Specialization,
+			&d.Contact,
+			&d.Activated,
+			&d.Shifts,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		doctors = append(doctors, &d)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return doctors, metadata, nil
 }
@@ -0,0 +1,140 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// IdempotentResponse is the response stored for an idempotency key and
+// replayed for every retry of the request.
+type IdempotentResponse struct {
+	StatusCode int
+	Header     map[string][]string
+	Body       []byte
+}
+
+// IdempotencyKey ties a client-chosen key to the request first sent with it.
+// Response is nil while that request is still being handled.
+type IdempotencyKey struct {
+	Owner       string
+	Key         string
+	Fingerprint []byte
+	ExpiresAt   time.Time
+	Response    *IdempotentResponse
+}
+
+type IdempotencyKeyModel struct {
+	DB *sql.DB
+}
+
+// Reserve claims key for a new request. If the key is already taken and not
+// yet expired, the existing record is returned instead and reserved is false.
+func (m IdempotencyKeyModel) Reserve(owner, key string, fingerprint []byte, ttl time.Duration) (existing *IdempotencyKey, reserved bool, err error) {
+	query := `
+		INSERT INTO idempotency_keys (owner, key, fingerprint, expires_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (owner, key) DO UPDATE
+		SET fingerprint = EXCLUDED.fingerprint, created_at = NOW(), expires_at = EXCLUDED.expires_at,
+			status_code = NULL, header = NULL, body = NULL
+		WHERE idempotency_keys.expires_at <= NOW()
+		RETURNING true
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err = m.DB.QueryRowContext(ctx, query, owner, key, fingerprint, time.Now().Add(ttl)).Scan(&reserved)
+	if err == nil {
+		return nil, true, nil
+	}
+
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, false, err
+	}
+
+	query = `
+		SELECT fingerprint, expires_at, status_code, header, body
+		FROM idempotency_keys
+		WHERE owner = $1 AND key = $2
+	`
+
+	existing = &IdempotencyKey{Owner: owner, Key: key}
+
+	var (
+		statusCode sql.NullInt32
+		header     []byte
+		body       []byte
+	)
+
+	err = m.DB.QueryRowContext(ctx, query, owner, key).Scan(&existing.Fingerprint, &existing.ExpiresAt, &statusCode, &header, &body)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, false, ErrRecordNotFound
+		default:
+			return nil, false, err
+		}
+	}
+
+	if statusCode.Valid {
+		existing.Response = &IdempotentResponse{
+			StatusCode: int(statusCode.Int32),
+			Body:       body,
+		}
+
+		err = json.Unmarshal(header, &existing.Response.Header)
+		if err != nil {
+			return nil, false, err
+		}
+	}
+
+	return existing, false, nil
+}
+
+// Complete stores the response to the request that reserved key.
+func (m IdempotencyKeyModel) Complete(owner, key string, response *IdempotentResponse) error {
+	query := `
+		UPDATE idempotency_keys
+		SET status_code = $3, header = $4, body = $5
+		WHERE owner = $1 AND key = $2
+	`
+
+	header, err := json.Marshal(response.Header)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err = m.DB.ExecContext(ctx, query, owner, key, response.StatusCode, string(header), response.Body)
+	return err
+}
+
+// Release frees a key whose request didn't complete, so that it can be
+// retried with the same key.
+func (m IdempotencyKeyModel) Release(owner, key string) error {
+	query := `
+		DELETE FROM idempotency_keys
+		WHERE owner = $1 AND key = $2 AND status_code IS NULL
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, owner, key)
+	return err
+}
+
+// DeleteExpired removes the keys whose responses are no longer replayed.
+func (m IdempotencyKeyModel) DeleteExpired(ctx context.Context) (int64, error) {
+	result, err := m.DB.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE expires_at < NOW()`)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
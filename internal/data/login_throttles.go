@@ -0,0 +1,193 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+const (
+	ThrottleEmail = "email"
+	ThrottleIP    = "ip"
+)
+
+// LoginThrottle counts the recent failed logins for an email address or a
+// client IP, including attempts that are still being checked.
+type LoginThrottle struct {
+	Kind          string
+	Subject       string
+	Failures      int
+	LastFailureAt time.Time
+	LockedUntil   *time.Time
+}
+
+// ThrottlePolicy decides how long a client has to wait after failed logins.
+// Every failure doubles the wait, starting at BaseDelay, and MaxFailures of
+// them in a row lock the subject out for Lockout. Failures older than
+// Lockout are forgotten.
+type ThrottlePolicy struct {
+	MaxFailures int
+	BaseDelay   time.Duration
+	Lockout     time.Duration
+}
+
+// Wait returns how long the subject must wait before its next attempt.
+func (p ThrottlePolicy) Wait(t *LoginThrottle, now time.Time) time.Duration {
+	if t == nil {
+		return 0
+	}
+
+	if t.LockedUntil != nil && now.Before(*t.LockedUntil) {
+		return t.LockedUntil.Sub(now)
+	}
+
+	if t.Failures == 0 || now.Sub(t.LastFailureAt) >= p.Lockout {
+		return 0
+	}
+
+	delay := p.BaseDelay
+	for i := 1; i < t.Failures && delay < p.Lockout; i++ {
+		delay *= 2
+	}
+
+	delay = min(delay, p.Lockout)
+
+	return max(t.LastFailureAt.Add(delay).Sub(now), 0)
+}
+
+type LoginThrottleModel struct {
+	DB *sql.DB
+}
+
+// LoginAttempt is a login attempt reserved with Attempt.
+type LoginAttempt struct {
+	Kind    string
+	Subject string
+	// Locked is set when the attempt locked the subject out.
+	Locked bool
+
+	at            time.Time
+	lastFailureAt time.Time
+}
+
+// Attempt reserves a login attempt for the subject. The attempt counts as a
+// failure until it is released, or the subject cleared, once the credentials
+// turn out to be right. The policy is checked and the attempt counted while
+// the row is locked, so concurrent attempts can't all get past the check
+// before any of them is counted.
+//
+// When the subject still has to wait, nothing is reserved and the wait is
+// returned instead.
+func (m LoginThrottleModel) Attempt(kind, subject string, policy ThrottlePolicy) (*LoginAttempt, time.Duration, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+
+	// The upsert locks the row until the transaction ends.
+	query := `
+		INSERT INTO login_throttles (kind, subject)
+		VALUES ($1, $2)
+		ON CONFLICT (kind, subject) DO UPDATE SET kind = EXCLUDED.kind
+		RETURNING failures, last_failure_at, locked_until, NOW()
+	`
+
+	t := LoginThrottle{Kind: kind, Subject: subject}
+
+	var now time.Time
+
+	err = tx.QueryRowContext(ctx, query, kind, subject).Scan(&t.Failures, &t.LastFailureAt, &t.LockedUntil, &now)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	wait := policy.Wait(&t, now)
+	if wait > 0 {
+		return nil, wait, tx.Commit()
+	}
+
+	a := &LoginAttempt{
+		Kind:          kind,
+		Subject:       subject,
+		at:            now.Truncate(time.Second),
+		lastFailureAt: t.LastFailureAt,
+	}
+
+	// Failures older than the lockout period start the count again.
+	if now.Sub(t.LastFailureAt) >= policy.Lockout {
+		t.Failures = 0
+	}
+
+	t.Failures++
+	t.LastFailureAt = a.at
+	t.LockedUntil = nil
+
+	if t.Failures >= policy.MaxFailures {
+		lockedUntil := a.at.Add(policy.Lockout)
+		t.LockedUntil = &lockedUntil
+		a.Locked = true
+	}
+
+	query = `
+		UPDATE login_throttles
+		SET failures = $3, last_failure_at = $4, locked_until = $5
+		WHERE kind = $1 AND subject = $2
+	`
+
+	_, err = tx.ExecContext(ctx, query, kind, subject, t.Failures, t.LastFailureAt, t.LockedUntil)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return a, 0, tx.Commit()
+}
+
+// Release takes back an attempt whose credentials were right. Its time is
+// taken back too unless another attempt has been made since, so that
+// successful logins don't keep older failures from being forgotten. If the
+// attempt locked the subject out, the lockout is lifted.
+func (m LoginThrottleModel) Release(a *LoginAttempt, policy ThrottlePolicy) error {
+	query := `
+		UPDATE login_throttles
+		SET failures = failures - 1,
+			last_failure_at = CASE WHEN last_failure_at = $3 THEN $4 ELSE last_failure_at END,
+			locked_until = CASE WHEN failures - 1 < $5 THEN NULL ELSE locked_until END
+		WHERE kind = $1 AND subject = $2 AND failures > 0
+	`
+
+	args := []any{a.Kind, a.Subject, a.at, a.lastFailureAt, policy.MaxFailures}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, args...)
+	return err
+}
+
+// Clear forgets the failures of the subject and lifts any lockout. It reports
+// whether there was anything to clear.
+func (m LoginThrottleModel) Clear(kind, subject string) (bool, error) {
+	query := `
+		DELETE FROM login_throttles
+		WHERE kind = $1 AND subject = $2
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, kind, subject)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rowsAffected > 0, nil
+}
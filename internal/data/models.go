@@ -16,6 +16,20 @@ type Models struct {
 	Tokens        TokenModel
 	Patients      PatientModel
 	Doctors       DoctorModel
+	PatientShares PatientShareModel
+	Permissions   PermissionModel
+	Admins        AdminModel
+	Appointments  AppointmentModel
+	DoctorBreaks  DoctorBreakModel
+	Exceptions    ScheduleExceptionModel
+	Overrides     ShiftOverrideModel
+	AuditEvents   AuditEventModel
+	Revisions     PatientRevisionModel
+	Idempotency   IdempotencyKeyModel
+	Sessions      SessionModel
+	TwoFactor     TwoFactorModel
+	Throttles     LoginThrottleModel
+	Security      SecurityEventModel
 }
 
 func NewModels(db *sql.DB) Models {
@@ -32,5 +46,47 @@ func NewModels(db *sql.DB) Models {
 		Doctors: DoctorModel{
 			DB: db,
 		},
+		PatientShares: PatientShareModel{
+			DB: db,
+		},
+		Permissions: PermissionModel{
+			DB: db,
+		},
+		Admins: AdminModel{
+			DB: db,
+		},
+		Appointments: AppointmentModel{
+			DB: db,
+		},
+		DoctorBreaks: DoctorBreakModel{
+			DB: db,
+		},
+		Exceptions: ScheduleExceptionModel{
+			DB: db,
+		},
+		Overrides: ShiftOverrideModel{
+			DB: db,
+		},
+		AuditEvents: AuditEventModel{
+			DB: db,
+		},
+		Revisions: PatientRevisionModel{
+			DB: db,
+		},
+		Idempotency: IdempotencyKeyModel{
+			DB: db,
+		},
+		Sessions: SessionModel{
+			DB: db,
+		},
+		TwoFactor: TwoFactorModel{
+			DB: db,
+		},
+		Throttles: LoginThrottleModel{
+			DB: db,
+		},
+		Security: SecurityEventModel{
+			DB: db,
+		},
 	}
 }
@@ -0,0 +1,134 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// PatientRevision is a patient record as it was stored at one version.
+type PatientRevision struct {
+	Patient
+	RevisedAt time.Time `json:"revised_at"`
+}
+
+type PatientRevisionModel struct {
+	DB *sql.DB
+}
+
+// recordPatientRevision copies the patient's current row into
+// patient_revisions within tx, so every version that is written is kept.
+func recordPatientRevision(ctx context.Context, tx *sql.Tx, patientID int64) error {
+	query := `
+		INSERT INTO patient_revisions (patient_id, version, name, gender, age, contact, address, medical_history, insurance_info, last_visit, doctor_id)
+		SELECT id, version, name, gender, age, contact, address, medical_history, insurance_info, last_visit, doctor_id
+		FROM patients
+		WHERE id = $1
+	`
+
+	_, err := tx.ExecContext(ctx, query, patientID)
+	return err
+}
+
+func (m PatientRevisionModel) GetAllForPatient(patientID int64, filters Filters) ([]*PatientRevision, Metadata, error) {
+	query := fmt.Sprintf(`
+		SELECT count(*) OVER(), r.patient_id, p.created_at, r.name, r.gender, r.age, r.contact, r.address, r.medical_history, r.insurance_info, r.last_visit, r.doctor_id, r.version, r.revised_at
+		FROM patient_revisions r
+		INNER JOIN patients p ON p.id = r.patient_id
+		WHERE r.patient_id = $1
+		ORDER BY r.%s %s
+		LIMIT $2 OFFSET $3
+	`, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, patientID, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	revisions := []*PatientRevision{}
+
+	for rows.Next() {
+		var r PatientRevision
+
+		err := rows.Scan(
+			&totalRecords,
+			&r.ID,
+			&r.CreatedAt,
+			&r.Name,
+			&r.Gender,
+			&r.Age,
+			&r.Contact,
+			&r.Address,
+			&r.MedicalHistory,
+			&r.InsuranceInfo,
+			&r.LastVisit,
+			&r.DoctorID,
+			&r.Version,
+			&r.RevisedAt,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		revisions = append(revisions, &r)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return revisions, metadata, nil
+}
+
+func (m PatientRevisionModel) Get(patientID, version int64) (*PatientRevision, error) {
+	if patientID < 1 || version < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT r.patient_id, p.created_at, r.name, r.gender, r.age, r.contact, r.address, r.medical_history, r.insurance_info, r.last_visit, r.doctor_id, r.version, r.revised_at
+		FROM patient_revisions r
+		INNER JOIN patients p ON p.id = r.patient_id
+		WHERE r.patient_id = $1 AND r.version = $2
+	`
+
+	var r PatientRevision
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, patientID, version).Scan(
+		&r.ID,
+		&r.CreatedAt,
+		&r.Name,
+		&r.Gender,
+		&r.Age,
+		&r.Contact,
+		&r.Address,
+		&r.MedicalHistory,
+		&r.InsuranceInfo,
+		&r.LastVisit,
+		&r.DoctorID,
+		&r.Version,
+		&r.RevisedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+
+		default:
+			return nil, err
+		}
+	}
+
+	return &r, nil
+}
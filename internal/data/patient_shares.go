@@ -0,0 +1,111 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// PatientShareModel records patients that have been explicitly shared with a
+// doctor other than the one they are assigned to.
+type PatientShareModel struct {
+	DB *sql.DB
+}
+
+// Insert shares the patient with the doctor and appends event to the audit
+// trail in the same transaction.
+func (m PatientShareModel) Insert(patientID, doctorID int64, event *AuditEvent) error {
+	query := `
+		INSERT INTO patient_shares (patient_id, doctor_id)
+		VALUES ($1, $2)
+		ON CONFLICT DO NOTHING
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, query, patientID, doctorID)
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), `violates foreign key constraint "patient_shares_doctor_id_fkey"`):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	event.PatientID = patientID
+
+	err = appendAuditEvents(ctx, tx, event)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Delete stops sharing the patient with the doctor and appends event to the
+// audit trail in the same transaction.
+func (m PatientShareModel) Delete(patientID, doctorID int64, event *AuditEvent) error {
+	query := `
+		DELETE FROM patient_shares
+		WHERE patient_id = $1 AND doctor_id = $2
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, query, patientID, doctorID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	event.PatientID = patientID
+
+	err = appendAuditEvents(ctx, tx, event)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (m PatientShareModel) Exists(patientID, doctorID int64) (bool, error) {
+	query := `
+		SELECT EXISTS (
+			SELECT 1 FROM patient_shares
+			WHERE patient_id = $1 AND doctor_id = $2
+		)
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var exists bool
+
+	err := m.DB.QueryRowContext(ctx, query, patientID, doctorID).Scan(&exists)
+
+	return exists, err
+}
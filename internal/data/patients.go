@@ -4,9 +4,12 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/0xMishra/makerble/internal/validator"
+	"github.com/lib/pq"
 )
 
 type PatientModel struct {
@@ -26,6 +29,10 @@ type Patient struct {
 	LastVisit      time.Time `json:"last_visit"`
 	Version        int64     `json:"version"`
 	DoctorID       int64     `json:"doctor_id"`
+	// The deletion fields are only set on archived patients.
+	DeletedAt    *time.Time `json:"deleted_at,omitempty"`
+	DeletedBy    string     `json:"deleted_by,omitempty"`
+	DeleteReason string     `json:"delete_reason,omitempty"`
 }
 
 func ValidatePatient(v *validator.Validator, p *Patient) {
@@ -43,7 +50,10 @@ func ValidatePatient(v *validator.Validator, p *Patient) {
 	v.Check(p.DoctorID >= 0, "doctor id", "doctor's id must be provided")
 }
 
-func (m PatientModel) Insert(p *Patient) error {
+// Insert adds the patient and appends event to the audit trail in the same
+// transaction. It fails with ErrRecordNotFound when the doctor doesn't exist. The event's patient ID and changes are filled in, as they
+// depend on values the database sets.
+func (m PatientModel) Insert(p *Patient, event *AuditEvent) error {
 	query := `
 		INSERT INTO patients (name, gender, age, contact, address, medical_history, insurance_info, last_visit, doctor_id)
 		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
@@ -65,7 +75,13 @@ func (m PatientModel) Insert(p *Patient) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	err := m.DB.QueryRowContext(ctx, query, args...).Scan(
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(
 		&p.ID,
 		&p.CreatedAt,
 		&p.Version,
@@ -75,25 +91,59 @@ func (m PatientModel) Insert(p *Patient) error {
 		case err.Error() == `pq: duplicate key value violates unique constraint "users_email_key"`:
 			return ErrDuplicateEmail
 
+		// The doctor was removed after the handler checked for them.
+		case strings.Contains(err.Error(), `violates foreign key constraint "patients_doctor_id_fkey"`):
+			return ErrRecordNotFound
+
 		default:
 			return err
 		}
 	}
 
-	return nil
+	err = recordPatientRevision(ctx, tx, p.ID)
+	if err != nil {
+		return err
+	}
+
+	event.PatientID = p.ID
+
+	event.Changes, err = PatientChanges(nil, p)
+	if err != nil {
+		return err
+	}
+
+	err = appendAuditEvents(ctx, tx, event)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
+// GetByID leaves out archived patients, see GetArchivedByID.
 func (m PatientModel) GetByID(id int64) (*Patient, error) {
+	return m.get(id, false)
+}
+
+// GetArchivedByID only finds patients that have been deleted and not yet
+// purged.
+func (m PatientModel) GetArchivedByID(id int64) (*Patient, error) {
+	return m.get(id, true)
+}
+
+func (m PatientModel) get(id int64, archived bool) (*Patient, error) {
 	query := `
-		SELECT id, created_at, name, gender, age, contact, address, medical_history, insurance_info, last_visit, doctor_id, version
+		SELECT id, created_at, name, gender, age, contact, address, medical_history, insurance_info, last_visit, doctor_id, version,
+			deleted_at, COALESCE(deleted_by, ''), COALESCE(delete_reason, '')
 		FROM patients
 		WHERE id = $1
+		AND (deleted_at IS NOT NULL) = $2
 	`
 	var p Patient
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+	err := m.DB.QueryRowContext(ctx, query, id, archived).Scan(
 		&p.ID,
 		&p.CreatedAt,
 		&p.Name,
@@ -106,6 +156,9 @@ func (m PatientModel) GetByID(id int64) (*Patient, error) {
 		&p.LastVisit,
 		&p.DoctorID,
 		&p.Version,
+		&p.DeletedAt,
+		&p.DeletedBy,
+		&p.DeleteReason,
 	)
 	if err != nil {
 		switch {
@@ -120,11 +173,13 @@ func (m PatientModel) GetByID(id int64) (*Patient, error) {
 	return &p, nil
 }
 
-func (m PatientModel) Update(p *Patient) error {
+// Update saves the patient and appends event, which should already hold the
+// changes, to the audit trail in the same transaction.
+func (m PatientModel) Update(p *Patient, event *AuditEvent) error {
 	query := `
 		UPDATE patients
 		SET name = $1, gender = $2, age = $3, contact = $4, address = $5, medical_history = $6, insurance_info = $7, last_visit = $8, doctor_id = $9, version = version + 1
-		WHERE id = $10  AND version = $11
+		WHERE id = $10  AND version = $11 AND deleted_at IS NULL
 		RETURNING version
 	`
 
@@ -145,7 +200,13 @@ func (m PatientModel) Update(p *Patient) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	err := m.DB.QueryRowContext(ctx, query, args...).Scan(
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(
 		&p.Version,
 	)
 	if err != nil {
@@ -158,34 +219,396 @@ func (m PatientModel) Update(p *Patient) error {
 		}
 	}
 
-	return nil
+	err = recordPatientRevision(ctx, tx, p.ID)
+	if err != nil {
+		return err
+	}
+
+	event.PatientID = p.ID
+
+	err = appendAuditEvents(ctx, tx, event)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
-func (m PatientModel) Delete(id int64) error {
-	if id < 1 {
+// Delete archives the patient rather than removing the row, recording who
+// deleted it and why. The record stays out of lookups and listings until it
+// is restored or purged. Like Update, it fails with ErrEditConflict when the
+// patient has changed since p was read. event is filled in with the changes
+// and appended to the audit trail in the same transaction.
+func (m PatientModel) Delete(p *Patient, deletedBy, reason string, event *AuditEvent) error {
+	if p.ID < 1 {
 		return ErrRecordNotFound
 	}
 
 	query := `
-		DELETE FROM patients
-		WHERE id = $1
+		UPDATE patients
+		SET deleted_at = NOW(), deleted_by = $2, delete_reason = $3
+		WHERE id = $1 AND version = $4 AND deleted_at IS NULL
+		RETURNING deleted_at
+	`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var deletedAt time.Time
+
+	err = tx.QueryRowContext(ctx, query, p.ID, deletedBy, reason, p.Version).Scan(&deletedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+
+		default:
+			return err
+		}
+	}
+
+	before := *p
+
+	p.DeletedAt = &deletedAt
+	p.DeletedBy = deletedBy
+	p.DeleteReason = reason
+
+	event.PatientID = p.ID
+
+	event.Changes, err = PatientChanges(&before, p)
+	if err != nil {
+		return err
+	}
+
+	err = appendAuditEvents(ctx, tx, event)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Restore brings an archived patient back. Restoring is a change like any
+// other: it bumps the version and is recorded as a new revision. event is
+// filled in with the changes and appended to the audit trail in the same
+// transaction.
+func (m PatientModel) Restore(p *Patient, event *AuditEvent) error {
+	query := `
+		UPDATE patients
+		SET deleted_at = NULL, deleted_by = NULL, delete_reason = NULL, version = version + 1
+		WHERE id = $1 AND version = $2 AND deleted_at IS NOT NULL
+		RETURNING version
 	`
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	result, err := m.DB.ExecContext(ctx, query, id)
+	tx, err := m.DB.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
+
+	before := *p
+
+	err = tx.QueryRowContext(ctx, query, p.ID, p.Version).Scan(&p.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+
+		default:
+			return err
+		}
+	}
 
-	rowsAffected, err := result.RowsAffected()
+	err = recordPatientRevision(ctx, tx, p.ID)
 	if err != nil {
 		return err
 	}
 
-	if rowsAffected == 0 {
-		return ErrRecordNotFound
+	p.DeletedAt = nil
+	p.DeletedBy = ""
+	p.DeleteReason = ""
+
+	event.PatientID = p.ID
+
+	event.Changes, err = PatientChanges(&before, p)
+	if err != nil {
+		return err
+	}
+
+	err = appendAuditEvents(ctx, tx, event)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Purge permanently removes the patients archived before cutoff, together
+// with their revisions, shares and appointments, and returns their IDs. The
+// audit trail is kept, and a copy of event is appended to it for every purged
+// patient in the same transaction.
+func (m PatientModel) Purge(ctx context.Context, cutoff time.Time, event AuditEvent) ([]int64, error) {
+	query := `
+		DELETE FROM patients
+		WHERE deleted_at < $1
+		RETURNING id
+	`
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, query, cutoff)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int64{}
+	events := []*AuditEvent{}
+
+	for rows.Next() {
+		var id int64
+
+		err := rows.Scan(&id)
+		if err != nil {
+			return nil, err
+		}
+
+		e := event
+		e.PatientID = id
+
+		ids = append(ids, id)
+		events = append(events, &e)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// The rows have to be closed before tx can run anything else.
+	rows.Close()
+
+	if len(events) > 0 {
+		err = appendAuditEvents(ctx, tx, events...)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+// PatientFilters narrows down the patients returned by GetAll. Zero values
+// leave the corresponding condition out of the query. VisibleTo limits the
+// result to patients assigned to or shared with that doctor. Archived lists
+// deleted patients instead of current ones.
+type PatientFilters struct {
+	VisibleTo     int64
+	Archived      bool
+	Name          string
+	Genders       []string
+	DoctorID      int64
+	MinAge        int
+	MaxAge        int
+	LastVisitFrom time.Time
+	LastVisitTo   time.Time
+}
+
+func ValidatePatientFilters(v *validator.Validator, pf PatientFilters) {
+	for _, g := range pf.Genders {
+		v.Check(validator.PermittedValue(g, "male", "female", "others"), "gender", "gender can only be male, female or others")
+	}
+
+	v.Check(pf.DoctorID >= 0, "doctor_id", "must not be negative")
+	v.Check(pf.MinAge >= 0, "min_age", "must not be negative")
+	v.Check(pf.MaxAge >= 0, "max_age", "must not be negative")
+	v.Check(pf.MaxAge == 0 || pf.MaxAge >= pf.MinAge, "max_age", "must not be less than min_age")
+	v.Check(pf.LastVisitTo.IsZero() || !pf.LastVisitTo.Before(pf.LastVisitFrom), "last_visit_to", "must not be before last_visit_from")
+}
+
+func (m PatientModel) GetAll(pf PatientFilters, filters Filters) ([]*Patient, Metadata, error) {
+	query := fmt.Sprintf(`
+		SELECT count(*) OVER(), id, created_at, name, gender, age, contact, address, medical_history, insurance_info, last_visit, doctor_id, version,
+			deleted_at, COALESCE(deleted_by, ''), COALESCE(delete_reason, '')
+		FROM patients
+		WHERE (deleted_at IS NOT NULL) = $11
+		AND (name ILIKE '%%' || $1 || '%%' OR $1 = '')
+		AND (gender = ANY($2) OR cardinality($2::text[]) = 0)
+		AND (doctor_id = $3 OR $3 = 0)
+		AND age >= $4
+		AND (age <= $5 OR $5 = 0)
+		AND ($6::date IS NULL OR last_visit::date >= $6)
+		AND ($7::date IS NULL OR last_visit::date <= $7)
+		AND ($10 = 0 OR doctor_id = $10 OR id IN (SELECT patient_id FROM patient_shares WHERE doctor_id = $10))
+		ORDER BY %s %s, id ASC
+		LIMIT $8 OFFSET $9
+	`, filters.sortColumn(), filters.sortDirection())
+
+	args := []any{
+		pf.Name,
+		pq.Array(pf.Genders),
+		pf.DoctorID,
+		pf.MinAge,
+		pf.MaxAge,
+		sql.NullTime{Time: pf.LastVisitFrom, Valid: !pf.LastVisitFrom.IsZero()},
+		sql.NullTime{Time: pf.LastVisitTo, Valid: !pf.LastVisitTo.IsZero()},
+		filters.limit(),
+		filters.offset(),
+		pf.VisibleTo,
+		pf.Archived,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	patients := []*Patient{}
+
+	for rows.Next() {
+		var p Patient
+
+		err := rows.Scan(
+			&totalRecords,
+			&p.ID,
+			&p.CreatedAt,
+			&p.Name,
+			&p.Gender,
+			&p.Age,
+			&p.Contact,
+			&p.Address,
+			&p.MedicalHistory,
+			&p.InsuranceInfo,
+			&p.LastVisit,
+			&p.DoctorID,
+			&p.Version,
+			&p.DeletedAt,
+			&p.DeletedBy,
+			&p.DeleteReason,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		patients = append(patients, &p)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return patients, metadata, nil
+}
+
+// Search ranks patients against q using the full-text search column, trigram
+// similarity on the name to tolerate misspellings and, when q is made of
+// digits only, a prefix match on the contact number. A non-zero visibleTo
+// limits the result to patients assigned to or shared with that doctor.
+func (m PatientModel) Search(q string, visibleTo int64, filters Filters) ([]*Patient, Metadata, error) {
+	query := fmt.Sprintf(`
+		SELECT count(*) OVER(), id, created_at, name, gender, age, contact, address, medical_history, insurance_info, last_visit, doctor_id, version,
+			ts_rank(search, websearch_to_tsquery('english', $1)) + similarity(name, $1) AS rank
+		FROM patients
+		WHERE deleted_at IS NULL
+		AND (
+			search @@ websearch_to_tsquery('english', $1)
+			OR name %% $1
+			OR ($2 <> '' AND contact::text LIKE $2 || '%%')
+		)
+		AND ($5 = 0 OR doctor_id = $5 OR id IN (SELECT patient_id FROM patient_shares WHERE doctor_id = $5))
+		ORDER BY %s %s, id ASC
+		LIMIT $3 OFFSET $4
+	`, filters.sortColumn(), filters.sortDirection())
+
+	contactPrefix := ""
+	if isDigits(q) {
+		contactPrefix = q
+	}
+
+	args := []any{q, contactPrefix, filters.limit(), filters.offset(), visibleTo}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	patients := []*Patient{}
+
+	for rows.Next() {
+		var (
+			p    Patient
+			rank float64
+		)
+
+		err := rows.Scan(
+			&totalRecords,
+			&p.ID,
+			&p.CreatedAt,
+			&p.Name,
+			&p.Gender,
+			&p.Age,
+			&p.Contact,
+			&p.Address,
+			&p.MedicalHistory,
+			&p.InsuranceInfo,
+			&p.LastVisit,
+			&p.DoctorID,
+			&p.Version,
+			&rank,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		patients = append(patients, &p)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return patients, metadata, nil
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
 	}
 
-	return nil
+	return true
 }
@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"time"
 
 	"github.com/0xMishra/makerble/internal/validator"
@@ -16,14 +17,14 @@ type ReceptionistModel struct {
 }
 
 type Receptionist struct {
-	ID         int64              `json:"id"`
-	CreatedAt  time.Time          `json:"created_at"`
-	Name       string             `json:"name"`
-	Email      string             `json:"email"`
-	Password   validator.Password `json:"-"`
-	Version    int64              `json:"-"`
-	ShiftStart time.Time          `json:"shift_start"`
-	ShiftEnd   time.Time          `json:"shift_end"`
+	ID        int64              `json:"id"`
+	CreatedAt time.Time          `json:"created_at"`
+	Name      string             `json:"name"`
+	Email     string             `json:"email"`
+	Password  validator.Password `json:"-"`
+	Version   int64              `json:"-"`
+	Shifts    Schedule           `json:"shifts"`
+	Activated bool               `json:"activated"`
 }
 
 func ValidateReceptionist(v *validator.Validator, r *Receptionist) {
@@ -31,7 +32,7 @@ func ValidateReceptionist(v *validator.Validator, r *Receptionist) {
 	v.Check(len(r.Name) <= 500, "name", "name must be at most 500 bytes long")
 
 	validator.ValidateEmail(v, r.Email)
-	validator.ValidateShift(v, r.ShiftStart, r.ShiftEnd)
+	ValidateSchedule(v, r.Shifts)
 
 	if r.Password.Plaintext != nil {
 		validator.ValidatePlaintextPassword(v, *r.Password.Plaintext)
@@ -44,34 +45,45 @@ func ValidateReceptionist(v *validator.Validator, r *Receptionist) {
 
 func (m ReceptionistModel) Insert(r *Receptionist) error {
 	query := `
-		INSERT INTO receptionists (name, email, password_hash, shift_start, shift_end)
-		VALUES ($1, $2, $3, $4, $5)
-		RETURNING id, created_at, version
+		INSERT INTO receptionists (name, email, password_hash)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at, version, activated
 	`
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	args := []any{r.Name, r.Email, r.Password.Hash, r.ShiftStart, r.ShiftEnd}
+	args := []any{r.Name, r.Email, r.Password.Hash}
 
-	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&r.ID, &r.CreatedAt, &r.Version)
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&r.ID, &r.CreatedAt, &r.Version, &r.Activated)
 	if err != nil {
 		switch {
-		case err.Error() == `pq: duplicate key value violates unique constraint "users_email_key"`:
+		case err.Error() == `pq: duplicate key value violates unique constraint "receptionists_email_key"`:
 			return ErrDuplicateEmail
 		default:
 			return err
 		}
 	}
 
-	return nil
+	err = replaceShifts(ctx, tx, "receptionist", r.ID, r.Shifts)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
 func (m ReceptionistModel) Update(r *Receptionist) error {
 	query := `
 		UPDATE receptionists
-		SET name = $1, email = $2, password_hash = $3, shift_start = $4, shift_end = $5, version = version + 1
-		WHERE id = $6
+		SET name = $1, email = $2, password_hash = $3, activated = $4, version = version + 1
+		WHERE id = $5
 		RETURNING version
 	`
 
@@ -79,18 +91,23 @@ func (m ReceptionistModel) Update(r *Receptionist) error {
 		r.Name,
 		r.Email,
 		r.Password.Hash,
-		r.ShiftStart,
-		r.ShiftEnd,
+		r.Activated,
 		r.ID,
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&r.Version)
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&r.Version)
 	if err != nil {
 		switch {
-		case err.Error() == `pq: duplicate key value violates unique constraint "users_email_key"`:
+		case err.Error() == `pq: duplicate key value violates unique constraint "receptionists_email_key"`:
 			return ErrDuplicateEmail
 		case errors.Is(err, sql.ErrNoRows):
 			return ErrRecordNotFound
@@ -99,12 +116,17 @@ func (m ReceptionistModel) Update(r *Receptionist) error {
 		}
 	}
 
-	return nil
+	err = replaceShifts(ctx, tx, "receptionist", r.ID, r.Shifts)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
-func (m *ReceptionistModel) GetByEmail(email string) (*Receptionist, error) {
+func (m ReceptionistModel) GetByEmail(email string) (*Receptionist, error) {
 	query := `
-		SELECT id, created_at, name, email, shift_start, shift_end
+		SELECT id, created_at, name, email, password_hash, version, activated, ` + shiftsColumn("receptionist", "receptionists") + `
 		FROM receptionists
 		WHERE email = $1
 	`
@@ -115,10 +137,50 @@ func (m *ReceptionistModel) GetByEmail(email string) (*Receptionist, error) {
 
 	err := m.DB.QueryRowContext(ctx, query, email).Scan(
 		&r.ID,
+		&r.CreatedAt,
 		&r.Name,
+		&r.Email,
+		&r.Password.Hash,
+		&r.Version,
+		&r.Activated,
+		&r.Shifts,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &r, nil
+}
+
+func (m ReceptionistModel) GetByID(id int64) (*Receptionist, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT id, created_at, name, email, password_hash, version, activated, ` + shiftsColumn("receptionist", "receptionists") + `
+		FROM receptionists
+		WHERE id = $1
+	`
+
+	var r Receptionist
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+		&r.ID,
 		&r.CreatedAt,
-		&r.ShiftStart,
-		&r.ShiftEnd,
+		&r.Name,
+		&r.Email,
+		&r.Password.Hash,
+		&r.Version,
+		&r.Activated,
+		&r.Shifts,
 	)
 	if err != nil {
 		switch {
@@ -131,3 +193,54 @@ func (m *ReceptionistModel) GetByEmail(email string) (*Receptionist, error) {
 
 	return &r, nil
 }
+
+func (m ReceptionistModel) GetAll(name string, filters Filters) ([]*Receptionist, Metadata, error) {
+	query := fmt.Sprintf(`
+		SELECT count(*) OVER(), id, created_at, name, email, password_hash, version, activated, %s
+		FROM receptionists
+		WHERE (name ILIKE '%%' || $1 || '%%' OR $1 = '')
+		ORDER BY %s %s, id ASC
+		LIMIT $2 OFFSET $3
+	`, shiftsColumn("receptionist", "receptionists"), filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, name, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	receptionists := []*Receptionist{}
+
+	for rows.Next() {
+		var r Receptionist
+
+		err := rows.Scan(
+			&totalRecords,
+			&r.ID,
+			&r.CreatedAt,
+			&r.Name,
+			&r.Email,
+			&r.Password.Hash,
+			&r.Version,
+			&r.Activated,
+			&r.Shifts,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		receptionists = append(receptionists, &r)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return receptionists, metadata, nil
+}
@@ -0,0 +1,328 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/0xMishra/makerble/internal/validator"
+	"github.com/lib/pq"
+)
+
+const (
+	ExceptionLeave   = "leave"
+	ExceptionHoliday = "holiday"
+	ExceptionOnCall  = "on_call"
+)
+
+const (
+	ExceptionPending  = "pending"
+	ExceptionApproved = "approved"
+	ExceptionRejected = "rejected"
+)
+
+// ScheduleException is a period in which a member of staff doesn't follow
+// their usual shifts: leave, a public holiday that applies to everyone or an
+// on-call assignment. Only approved exceptions affect the rota and
+// availability.
+type ScheduleException struct {
+	ID          int64      `json:"id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	Kind        string     `json:"kind"`
+	StaffRole   string     `json:"staff_role,omitempty"`
+	StaffID     int64      `json:"staff_id,omitempty"`
+	StartsAt    time.Time  `json:"starts_at"`
+	EndsAt      time.Time  `json:"ends_at"`
+	Status      string     `json:"status"`
+	Reason      string     `json:"reason"`
+	RequestedBy string     `json:"requested_by"`
+	ReviewedBy  string     `json:"reviewed_by,omitempty"`
+	ReviewedAt  *time.Time `json:"reviewed_at,omitempty"`
+	Version     int32      `json:"version"`
+}
+
+func ValidateScheduleException(v *validator.Validator, e *ScheduleException) {
+	v.Check(validator.PermittedValue(e.Kind, ExceptionLeave, ExceptionHoliday, ExceptionOnCall), "kind", "must be leave, holiday or on_call")
+	v.Check(validator.PermittedValue(e.Status, ExceptionPending, ExceptionApproved, ExceptionRejected), "status", "must be pending, approved or rejected")
+
+	if e.Kind == ExceptionHoliday {
+		v.Check(e.StaffRole == "" && e.StaffID == 0, "staff_id", "must not be set for a holiday")
+	} else {
+		v.Check(validator.PermittedValue(e.StaffRole, "doctor", "receptionist"), "staff_role", "must be doctor or receptionist")
+		v.Check(e.StaffID > 0, "staff_id", "must be a positive integer")
+	}
+
+	v.Check(!e.StartsAt.IsZero(), "starts_at", "must be provided")
+	v.Check(!e.EndsAt.IsZero(), "ends_at", "must be provided")
+	v.Check(e.EndsAt.After(e.StartsAt), "ends_at", "must be after starts_at")
+	v.Check(e.EndsAt.Sub(e.StartsAt) <= 366*24*time.Hour, "ends_at", "must be at most a year after starts_at")
+	v.Check(len(e.Reason) <= 1000, "reason", "must not be more than 1000 bytes long")
+}
+
+// Blocks reports whether the exception takes the staff member off duty.
+func (e *ScheduleException) Blocks() bool {
+	return e.Kind == ExceptionLeave || e.Kind == ExceptionHoliday
+}
+
+// Covers reports whether t falls within the exception.
+func (e *ScheduleException) Covers(t time.Time) bool {
+	return !t.Before(e.StartsAt) && t.Before(e.EndsAt)
+}
+
+// ScheduleExceptionFilters narrows down the exceptions returned by GetAll.
+// Zero values leave the corresponding condition out of the query.
+type ScheduleExceptionFilters struct {
+	Kind      string
+	StaffRole string
+	StaffID   int64
+	Status    string
+	From      time.Time
+	To        time.Time
+}
+
+type ScheduleExceptionModel struct {
+	DB *sql.DB
+}
+
+func (m ScheduleExceptionModel) Insert(e *ScheduleException) error {
+	query := `
+		INSERT INTO staff_schedule_exceptions (kind, staff_role, staff_id, starts_at, ends_at, status, reason, requested_by)
+		VALUES ($1, NULLIF($2, ''), NULLIF($3, 0), $4, $5, $6, $7, $8)
+		RETURNING id, created_at, version
+	`
+
+	args := []any{e.Kind, e.StaffRole, e.StaffID, e.StartsAt, e.EndsAt, e.Status, e.Reason, e.RequestedBy}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(&e.ID, &e.CreatedAt, &e.Version)
+}
+
+func (m ScheduleExceptionModel) GetByID(id int64) (*ScheduleException, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT id, created_at, kind, COALESCE(staff_role, ''), COALESCE(staff_id, 0), starts_at, ends_at, status, reason, requested_by, COALESCE(reviewed_by, ''), reviewed_at, version
+		FROM staff_schedule_exceptions
+		WHERE id = $1
+	`
+
+	var e ScheduleException
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+		&e.ID,
+		&e.CreatedAt,
+		&e.Kind,
+		&e.StaffRole,
+		&e.StaffID,
+		&e.StartsAt,
+		&e.EndsAt,
+		&e.Status,
+		&e.Reason,
+		&e.RequestedBy,
+		&e.ReviewedBy,
+		&e.ReviewedAt,
+		&e.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+
+		default:
+			return nil, err
+		}
+	}
+
+	return &e, nil
+}
+
+func (m ScheduleExceptionModel) GetAll(ef ScheduleExceptionFilters, filters Filters) ([]*ScheduleException, Metadata, error) {
+	query := fmt.Sprintf(`
+		SELECT count(*) OVER(), id, created_at, kind, COALESCE(staff_role, ''), COALESCE(staff_id, 0), starts_at, ends_at, status, reason, requested_by, COALESCE(reviewed_by, ''), reviewed_at, version
+		FROM staff_schedule_exceptions
+		WHERE (kind = $1 OR $1 = '')
+		AND (staff_role = $2 OR $2 = '')
+		AND (staff_id = $3 OR $3 = 0)
+		AND (status = $4 OR $4 = '')
+		AND ($5::timestamptz IS NULL OR ends_at > $5)
+		AND ($6::timestamptz IS NULL OR starts_at < $6)
+		ORDER BY %s %s, id ASC
+		LIMIT $7 OFFSET $8
+	`, filters.sortColumn(), filters.sortDirection())
+
+	args := []any{
+		ef.Kind,
+		ef.StaffRole,
+		ef.StaffID,
+		ef.Status,
+		sql.NullTime{Time: ef.From, Valid: !ef.From.IsZero()},
+		sql.NullTime{Time: ef.To, Valid: !ef.To.IsZero()},
+		filters.limit(),
+		filters.offset(),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	exceptions := []*ScheduleException{}
+
+	for rows.Next() {
+		var e ScheduleException
+
+		err := rows.Scan(
+			&totalRecords,
+			&e.ID,
+			&e.CreatedAt,
+			&e.Kind,
+			&e.StaffRole,
+			&e.StaffID,
+			&e.StartsAt,
+			&e.EndsAt,
+			&e.Status,
+			&e.Reason,
+			&e.RequestedBy,
+			&e.ReviewedBy,
+			&e.ReviewedAt,
+			&e.Version,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		exceptions = append(exceptions, &e)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return exceptions, metadata, nil
+}
+
+// GetApproved returns the approved exceptions intersecting [from, to) that
+// belong to one of the given staff members of role, together with every
+// public holiday in that window.
+func (m ScheduleExceptionModel) GetApproved(role string, staffIDs []int64, from, to time.Time) ([]*ScheduleException, error) {
+	query := `
+		SELECT id, created_at, kind, COALESCE(staff_role, ''), COALESCE(staff_id, 0), starts_at, ends_at, status, reason, requested_by, COALESCE(reviewed_by, ''), reviewed_at, version
+		FROM staff_schedule_exceptions
+		WHERE status = 'approved'
+		AND (kind = 'holiday' OR (staff_role = $1 AND staff_id = ANY($2)))
+		AND tstzrange(starts_at, ends_at) && tstzrange($3, $4)
+		ORDER BY starts_at, id
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, role, pq.Array(staffIDs), from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	exceptions := []*ScheduleException{}
+
+	for rows.Next() {
+		var e ScheduleException
+
+		err := rows.Scan(
+			&e.ID,
+			&e.CreatedAt,
+			&e.Kind,
+			&e.StaffRole,
+			&e.StaffID,
+			&e.StartsAt,
+			&e.EndsAt,
+			&e.Status,
+			&e.Reason,
+			&e.RequestedBy,
+			&e.ReviewedBy,
+			&e.ReviewedAt,
+			&e.Version,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		exceptions = append(exceptions, &e)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return exceptions, nil
+}
+
+// Update records a review of the exception. It fails with ErrEditConflict
+// when the exception changed since it was read.
+func (m ScheduleExceptionModel) Update(e *ScheduleException) error {
+	query := `
+		UPDATE staff_schedule_exceptions
+		SET status = $1, reviewed_by = NULLIF($2, ''), reviewed_at = $3, version = version + 1
+		WHERE id = $4 AND version = $5
+		RETURNING version
+	`
+
+	args := []any{e.Status, e.ReviewedBy, e.ReviewedAt, e.ID, e.Version}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&e.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m ScheduleExceptionModel) Delete(id int64) error {
+	query := `
+		DELETE FROM staff_schedule_exceptions
+		WHERE id = $1
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
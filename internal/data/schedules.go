@@ -0,0 +1,126 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/0xMishra/makerble/internal/validator"
+)
+
+var ClockRX = regexp.MustCompile(`^([01][0-9]|2[0-3]):[0-5][0-9]$`)
+
+// DoctorBreak is a recurring pause within a doctor's shift. A nil Weekday
+// repeats the break every day. Like a Shift, a break whose end isn't after its
+// start runs past midnight into the following day.
+type DoctorBreak struct {
+	ID        int64  `json:"id"`
+	DoctorID  int64  `json:"doctor_id"`
+	Weekday   *int   `json:"weekday"`
+	StartTime string `json:"start_time"`
+	EndTime   string `json:"end_time"`
+}
+
+func ValidateDoctorBreak(v *validator.Validator, b *DoctorBreak) {
+	if b.Weekday != nil {
+		v.Check(*b.Weekday >= 0 && *b.Weekday <= 6, "weekday", "must be between 0 (Sunday) and 6 (Saturday)")
+	}
+
+	v.Check(validator.Matches(b.StartTime, ClockRX), "start_time", "must be a time in the HH:MM format")
+	v.Check(validator.Matches(b.EndTime, ClockRX), "end_time", "must be a time in the HH:MM format")
+	v.Check(b.EndTime != b.StartTime, "end_time", "must not be the same as start_time")
+}
+
+type DoctorBreakModel struct {
+	DB *sql.DB
+}
+
+func (m DoctorBreakModel) Insert(b *DoctorBreak) error {
+	query := `
+		INSERT INTO doctor_breaks (doctor_id, weekday, start_time, end_time)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id
+	`
+
+	args := []any{b.DoctorID, b.Weekday, b.StartTime, b.EndTime}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&b.ID)
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), `violates foreign key constraint`):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m DoctorBreakModel) GetAllForDoctor(doctorID int64) ([]*DoctorBreak, error) {
+	query := `
+		SELECT id, doctor_id, weekday, to_char(start_time, 'HH24:MI'), to_char(end_time, 'HH24:MI')
+		FROM doctor_breaks
+		WHERE doctor_id = $1
+		ORDER BY weekday NULLS FIRST, start_time
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, doctorID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	breaks := []*DoctorBreak{}
+
+	for rows.Next() {
+		var b DoctorBreak
+
+		err := rows.Scan(&b.ID, &b.DoctorID, &b.Weekday, &b.StartTime, &b.EndTime)
+		if err != nil {
+			return nil, err
+		}
+
+		breaks = append(breaks, &b)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return breaks, nil
+}
+
+func (m DoctorBreakModel) Delete(id, doctorID int64) error {
+	query := `
+		DELETE FROM doctor_breaks
+		WHERE id = $1 AND doctor_id = $2
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id, doctorID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
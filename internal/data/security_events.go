@@ -0,0 +1,121 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+const (
+	SecurityLoginSucceeded  = "login_succeeded"
+	SecurityLoginFailed     = "login_failed"
+	SecurityLoginBlocked    = "login_blocked"
+	SecurityAccountLocked   = "account_locked"
+	SecurityAccountUnlocked = "account_unlocked"
+	SecurityTwoFactorFailed = "two_factor_failed"
+)
+
+// SecurityEvents lists every kind of security event, for validating filters.
+var SecurityEvents = []string{
+	SecurityLoginSucceeded,
+	SecurityLoginFailed,
+	SecurityLoginBlocked,
+	SecurityAccountLocked,
+	SecurityAccountUnlocked,
+	SecurityTwoFactorFailed,
+}
+
+// SecurityEvent records a sign-in attempt or a change to an account's
+// lockout. Email is the address that was tried, which need not belong to an
+// account, and Role is empty when no account was matched.
+type SecurityEvent struct {
+	ID        int64     `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	Kind      string    `json:"kind"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role,omitempty"`
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"user_agent"`
+	Details   string    `json:"details,omitempty"`
+}
+
+type SecurityEventFilters struct {
+	Email string
+	Kind  string
+	IP    string
+}
+
+type SecurityEventModel struct {
+	DB *sql.DB
+}
+
+func (m SecurityEventModel) Insert(e *SecurityEvent) error {
+	query := `
+		INSERT INTO security_events (kind, email, role, ip, user_agent, details)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at
+	`
+
+	args := []any{e.Kind, e.Email, e.Role, e.IP, e.UserAgent, e.Details}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(&e.ID, &e.CreatedAt)
+}
+
+func (m SecurityEventModel) GetAll(sf SecurityEventFilters, filters Filters) ([]*SecurityEvent, Metadata, error) {
+	query := fmt.Sprintf(`
+		SELECT count(*) OVER(), id, created_at, kind, email, role, ip, user_agent, details
+		FROM security_events
+		WHERE (email = $1 OR $1 = '')
+		AND (kind = $2 OR $2 = '')
+		AND (ip = $3 OR $3 = '')
+		ORDER BY %s %s, id ASC
+		LIMIT $4 OFFSET $5
+	`, filters.sortColumn(), filters.sortDirection())
+
+	args := []any{sf.Email, sf.Kind, sf.IP, filters.limit(), filters.offset()}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	events := []*SecurityEvent{}
+
+	for rows.Next() {
+		var e SecurityEvent
+
+		err := rows.Scan(
+			&totalRecords,
+			&e.ID,
+			&e.CreatedAt,
+			&e.Kind,
+			&e.Email,
+			&e.Role,
+			&e.IP,
+			&e.UserAgent,
+			&e.Details,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		events = append(events, &e)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return events, metadata, nil
+}
@@ -0,0 +1,120 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// Session is one login of a user and the token family it started. Signing
+// out deletes the session, which takes all its tokens with it.
+type Session struct {
+	ID         int64     `json:"id"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	UserAgent  string    `json:"device"`
+	IP         string    `json:"ip"`
+	Current    bool      `json:"current"`
+	Family     string    `json:"-"`
+}
+
+type SessionModel struct {
+	DB *sql.DB
+}
+
+// GetAllForUser lists the sessions of the account, most recently used first.
+// The one started by the currentFamily token is marked as current.
+func (m SessionModel) GetAllForUser(email, role, currentFamily string) ([]*Session, error) {
+	query := `
+		SELECT id, created_at, last_used_at, user_agent, ip, family
+		FROM sessions
+		WHERE email = $1 AND role = $2
+		ORDER BY last_used_at DESC, id DESC
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, email, role)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []*Session{}
+
+	for rows.Next() {
+		var s Session
+
+		err := rows.Scan(&s.ID, &s.CreatedAt, &s.LastUsedAt, &s.UserAgent, &s.IP, &s.Family)
+		if err != nil {
+			return nil, err
+		}
+
+		s.Current = s.Family == currentFamily
+
+		sessions = append(sessions, &s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+// Touch records that the session was used. It writes at most once a minute
+// so that authenticating a request doesn't always cost an update.
+func (m SessionModel) Touch(family string) error {
+	query := `
+		UPDATE sessions
+		SET last_used_at = NOW()
+		WHERE family = $1 AND last_used_at < NOW() - interval '1 minute'
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, family)
+	return err
+}
+
+// Delete signs out one session of the account.
+func (m SessionModel) Delete(id int64, email, role string) error {
+	query := `
+		DELETE FROM sessions
+		WHERE id = $1 AND email = $2 AND role = $3
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id, email, role)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+func (m SessionModel) DeleteByFamily(family string) error {
+	query := `
+		DELETE FROM sessions
+		WHERE family = $1
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, family)
+	return err
+}
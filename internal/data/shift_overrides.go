@@ -0,0 +1,144 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/0xMishra/makerble/internal/validator"
+)
+
+// ShiftOverride lets a receptionist make changes outside their shift between
+// StartsAt and EndsAt. It is granted by an admin and kept as a record of who
+// allowed it and why. Uses counts the requests made under it.
+type ShiftOverride struct {
+	ID             int64     `json:"id"`
+	CreatedAt      time.Time `json:"created_at"`
+	ReceptionistID int64     `json:"receptionist_id"`
+	StartsAt       time.Time `json:"starts_at"`
+	EndsAt         time.Time `json:"ends_at"`
+	Reason         string    `json:"reason"`
+	GrantedBy      string    `json:"granted_by"`
+	Uses           int       `json:"uses"`
+}
+
+func ValidateShiftOverride(v *validator.Validator, o *ShiftOverride) {
+	v.Check(!o.StartsAt.IsZero(), "starts_at", "must be provided")
+	v.Check(!o.EndsAt.IsZero(), "ends_at", "must be provided")
+	v.Check(o.EndsAt.After(o.StartsAt), "ends_at", "must be after starts_at")
+	v.Check(o.EndsAt.Sub(o.StartsAt) <= 24*time.Hour, "ends_at", "must be at most 24 hours after starts_at")
+	v.Check(o.Reason != "", "reason", "must be provided")
+	v.Check(len(o.Reason) <= 1000, "reason", "must not be more than 1000 bytes long")
+}
+
+type ShiftOverrideModel struct {
+	DB *sql.DB
+}
+
+func (m ShiftOverrideModel) Insert(o *ShiftOverride) error {
+	query := `
+		INSERT INTO shift_overrides (receptionist_id, starts_at, ends_at, reason, granted_by)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+	`
+
+	args := []any{o.ReceptionistID, o.StartsAt, o.EndsAt, o.Reason, o.GrantedBy}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&o.ID, &o.CreatedAt)
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), `violates foreign key constraint`):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m ShiftOverrideModel) GetAllForReceptionist(receptionistID int64) ([]*ShiftOverride, error) {
+	query := `
+		SELECT id, created_at, receptionist_id, starts_at, ends_at, reason, granted_by,
+			(SELECT count(*) FROM shift_override_uses WHERE override_id = shift_overrides.id)
+		FROM shift_overrides
+		WHERE receptionist_id = $1
+		ORDER BY starts_at DESC, id DESC
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, receptionistID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	overrides := []*ShiftOverride{}
+
+	for rows.Next() {
+		var o ShiftOverride
+
+		err := rows.Scan(&o.ID, &o.CreatedAt, &o.ReceptionistID, &o.StartsAt, &o.EndsAt, &o.Reason, &o.GrantedBy, &o.Uses)
+		if err != nil {
+			return nil, err
+		}
+
+		overrides = append(overrides, &o)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return overrides, nil
+}
+
+// Active returns the ID of an override the receptionist holds at t, or
+// ErrRecordNotFound when there is none.
+func (m ShiftOverrideModel) Active(receptionistID int64, t time.Time) (int64, error) {
+	query := `
+		SELECT id FROM shift_overrides
+		WHERE receptionist_id = $1
+		AND starts_at <= $2 AND ends_at > $2
+		ORDER BY ends_at DESC
+		LIMIT 1
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var id int64
+
+	err := m.DB.QueryRowContext(ctx, query, receptionistID, t).Scan(&id)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return 0, ErrRecordNotFound
+		default:
+			return 0, err
+		}
+	}
+
+	return id, nil
+}
+
+// RecordUse notes a request made under the override.
+func (m ShiftOverrideModel) RecordUse(overrideID int64, method, uri string) error {
+	query := `
+		INSERT INTO shift_override_uses (override_id, method, uri)
+		VALUES ($1, $2, $3)
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, overrideID, method, uri)
+	return err
+}
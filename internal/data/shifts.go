@@ -0,0 +1,293 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/0xMishra/makerble/internal/validator"
+)
+
+const minutesPerDay = 24 * 60
+
+var ErrInvalidClockTime = errors.New("must be a time in the HH:MM format")
+
+// ClockTime is a wall-clock time of day, stored as minutes since midnight.
+// It reads and writes as "HH:MM" in JSON and in the database.
+type ClockTime int
+
+func ParseClockTime(s string) (ClockTime, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, ErrInvalidClockTime
+	}
+
+	return ClockTime(t.Hour()*60 + t.Minute()), nil
+}
+
+func (c ClockTime) String() string {
+	return fmt.Sprintf("%02d:%02d", int(c)/60, int(c)%60)
+}
+
+func (c ClockTime) MarshalJSON() ([]byte, error) {
+	return json.Marshal(c.String())
+}
+
+func (c *ClockTime) UnmarshalJSON(b []byte) error {
+	var s string
+
+	err := json.Unmarshal(b, &s)
+	if err != nil {
+		return ErrInvalidClockTime
+	}
+
+	*c, err = ParseClockTime(s)
+	return err
+}
+
+func (c ClockTime) Value() (driver.Value, error) {
+	return c.String(), nil
+}
+
+func (c *ClockTime) Scan(src any) error {
+	switch v := src.(type) {
+	case time.Time:
+		*c = ClockTime(v.Hour()*60 + v.Minute())
+		return nil
+
+	case []byte:
+		return c.Scan(string(v))
+
+	case string:
+		t, err := time.Parse("15:04:05", v)
+		if err != nil {
+			return err
+		}
+		*c = ClockTime(t.Hour()*60 + t.Minute())
+		return nil
+	}
+
+	return fmt.Errorf("cannot scan %T into ClockTime", src)
+}
+
+// on returns the moment this clock time is reached on the day of t, in the
+// location of t.
+func (c ClockTime) on(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, int(c), 0, 0, t.Location())
+}
+
+// Shift is one working segment that starts on Weekday (0 is Sunday) at
+// Start. When End isn't after Start the segment runs past midnight and ends
+// at End on the following day.
+type Shift struct {
+	Weekday int       `json:"weekday"`
+	Start   ClockTime `json:"start"`
+	End     ClockTime `json:"end"`
+}
+
+func (s Shift) Overnight() bool {
+	return s.End <= s.Start
+}
+
+func (s Shift) minutes() int {
+	if s.Overnight() {
+		return int(s.End) + minutesPerDay - int(s.Start)
+	}
+
+	return int(s.End - s.Start)
+}
+
+// Schedule is the weekly working pattern of a member of staff. Weekdays
+// without a shift are days off and a day may hold several shifts.
+type Schedule []Shift
+
+// DailySchedule returns a schedule working from start to end on every day of
+// the week.
+func DailySchedule(start, end ClockTime) Schedule {
+	s := make(Schedule, 0, 7)
+
+	for weekday := range 7 {
+		s = append(s, Shift{Weekday: weekday, Start: start, End: end})
+	}
+
+	return s
+}
+
+func ValidateSchedule(v *validator.Validator, s Schedule) {
+	v.Check(len(s) > 0, "shifts", "must contain at least one shift")
+	v.Check(len(s) <= 50, "shifts", "must not contain more than 50 shifts")
+
+	type span struct{ start, end int }
+
+	const minutesPerWeek = 7 * minutesPerDay
+
+	spans := make([]span, 0, len(s))
+
+	for _, shift := range s {
+		v.Check(shift.Weekday >= 0 && shift.Weekday <= 6, "shifts", "weekday must be between 0 (Sunday) and 6 (Saturday)")
+		v.Check(shift.Start >= 0 && shift.Start < minutesPerDay, "shifts", "start must be a valid time of day")
+		v.Check(shift.End >= 0 && shift.End < minutesPerDay, "shifts", "end must be a valid time of day")
+		v.Check(shift.Start != shift.End, "shifts", "start and end must differ")
+
+		start := shift.Weekday*minutesPerDay + int(shift.Start)
+		spans = append(spans, span{start, start + shift.minutes()})
+	}
+
+	overlaps := func(a, b span) bool {
+		return a.start < b.end && b.start < a.end
+	}
+
+	for i := range spans {
+		for j := i + 1; j < len(spans); j++ {
+			a, b := spans[i], spans[j]
+
+			// A Saturday night shift wraps into Sunday morning, so every pair
+			// is also compared with one of them moved a week earlier.
+			earlierA := span{a.start - minutesPerWeek, a.end - minutesPerWeek}
+			earlierB := span{b.start - minutesPerWeek, b.end - minutesPerWeek}
+
+			if overlaps(a, b) || overlaps(earlierA, b) || overlaps(a, earlierB) {
+				v.AddError("shifts", "shifts must not overlap")
+				return
+			}
+		}
+	}
+}
+
+// Intervals returns the periods worked between from and to, with touching
+// segments merged, read as wall-clock times in the location of from.
+func (s Schedule) Intervals(from, to time.Time) []interval {
+	ivs := []interval{}
+
+	first := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, from.Location()).AddDate(0, 0, -1)
+
+	for day := first; day.Before(to); day = day.AddDate(0, 0, 1) {
+		for _, shift := range s {
+			if shift.Weekday != int(day.Weekday()) {
+				continue
+			}
+
+			start := shift.Start.on(day)
+			end := shift.End.on(day)
+			if shift.Overnight() {
+				end = shift.End.on(day.AddDate(0, 0, 1))
+			}
+
+			if start.Before(from) {
+				start = from
+			}
+			if end.After(to) {
+				end = to
+			}
+
+			if end.After(start) {
+				ivs = append(ivs, interval{start, end})
+			}
+		}
+	}
+
+	slices.SortFunc(ivs, func(a, b interval) int {
+		return a.start.Compare(b.start)
+	})
+
+	merged := ivs[:0]
+
+	for _, iv := range ivs {
+		if n := len(merged); n > 0 && !iv.start.After(merged[n-1].end) {
+			if iv.end.After(merged[n-1].end) {
+				merged[n-1].end = iv.end
+			}
+			continue
+		}
+
+		merged = append(merged, iv)
+	}
+
+	return merged
+}
+
+// Covers reports whether [start, end) lies within one continuous working
+// period, which may run across midnight.
+func (s Schedule) Covers(start, end time.Time) bool {
+	for _, iv := range s.Intervals(start.AddDate(0, 0, -1), end.AddDate(0, 0, 1)) {
+		if !start.Before(iv.start) && !end.After(iv.end) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// OnDuty reports whether t falls within a shift.
+func (s Schedule) OnDuty(t time.Time) bool {
+	for _, iv := range s.Intervals(t.AddDate(0, 0, -1), t.AddDate(0, 0, 1)) {
+		if !t.Before(iv.start) && t.Before(iv.end) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// OnDutyWithin reports whether t falls within a shift widened by grace on
+// either side.
+func (s Schedule) OnDutyWithin(t time.Time, grace time.Duration) bool {
+	if s.OnDuty(t) {
+		return true
+	}
+
+	return grace > 0 && len(s.Intervals(t.Add(-grace), t.Add(grace))) > 0
+}
+
+// Scan reads the JSON array built by the shiftsColumn subquery.
+func (s *Schedule) Scan(src any) error {
+	var b []byte
+
+	switch v := src.(type) {
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	default:
+		return fmt.Errorf("cannot scan %T into Schedule", src)
+	}
+
+	return json.Unmarshal(b, s)
+}
+
+// shiftsColumn selects the shifts of the staff member in table as a JSON
+// array that Schedule.Scan understands.
+func shiftsColumn(role, table string) string {
+	return fmt.Sprintf(`COALESCE((
+		SELECT json_agg(json_build_object('weekday', weekday, 'start', to_char(start_time, 'HH24:MI'), 'end', to_char(end_time, 'HH24:MI')) ORDER BY weekday, start_time)
+		FROM shifts
+		WHERE staff_role = '%s' AND staff_id = %s.id
+	), '[]')`, role, table)
+}
+
+// replaceShifts swaps the stored shifts of a staff member for s within tx.
+func replaceShifts(ctx context.Context, tx *sql.Tx, role string, staffID int64, s Schedule) error {
+	_, err := tx.ExecContext(ctx, `DELETE FROM shifts WHERE staff_role = $1 AND staff_id = $2`, role, staffID)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO shifts (staff_role, staff_id, weekday, start_time, end_time)
+		VALUES ($1, $2, $3, $4, $5)
+	`
+
+	for _, shift := range s {
+		_, err = tx.ExecContext(ctx, query, role, staffID, shift.Weekday, shift.Start, shift.End)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
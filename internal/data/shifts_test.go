@@ -0,0 +1,203 @@
+package data
+
+import (
+	"testing"
+	"time"
+
+	"github.com/0xMishra/makerble/internal/validator"
+)
+
+// clock parses an "HH:MM" time of day for use in test tables.
+func clock(s string) ClockTime {
+	c, err := ParseClockTime(s)
+	if err != nil {
+		panic(err)
+	}
+
+	return c
+}
+
+// at returns the time hhmm on the given day of March 2024 in UTC. The 2nd is
+// a Saturday, the 3rd a Sunday and the 4th a Monday.
+func at(day int, hhmm string) time.Time {
+	return clock(hhmm).on(time.Date(2024, time.March, day, 0, 0, 0, 0, time.UTC))
+}
+
+func shift(weekday int, start, end string) Shift {
+	return Shift{Weekday: weekday, Start: clock(start), End: clock(end)}
+}
+
+func TestValidateSchedule(t *testing.T) {
+	tests := []struct {
+		name      string
+		schedule  Schedule
+		wantValid bool
+	}{
+		{"every day", DailySchedule(clock("09:00"), clock("17:00")), true},
+		{"split day", Schedule{shift(1, "08:00", "12:00"), shift(1, "13:00", "17:00")}, true},
+		{"overnight", Schedule{shift(1, "22:00", "06:00")}, true},
+		{"overnight followed by a morning shift", Schedule{shift(1, "22:00", "06:00"), shift(2, "06:00", "09:00")}, true},
+		{"overnight overlapping a morning shift", Schedule{shift(1, "22:00", "06:00"), shift(2, "05:00", "09:00")}, false},
+		{"saturday night followed by sunday morning", Schedule{shift(6, "22:00", "06:00"), shift(0, "06:00", "08:00")}, true},
+		{"saturday night overlapping sunday morning", Schedule{shift(6, "22:00", "06:00"), shift(0, "05:00", "08:00")}, false},
+		{"sunday morning overlapping saturday night", Schedule{shift(0, "05:00", "08:00"), shift(6, "22:00", "06:00")}, false},
+		{"same shift twice", Schedule{shift(3, "09:00", "17:00"), shift(3, "09:00", "17:00")}, false},
+		{"overlapping on the same day", Schedule{shift(3, "09:00", "13:00"), shift(3, "12:00", "17:00")}, false},
+		{"start equals end", Schedule{shift(1, "09:00", "09:00")}, false},
+		{"weekday out of range", Schedule{shift(7, "09:00", "17:00")}, false},
+		{"no shifts", Schedule{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := validator.New()
+
+			ValidateSchedule(v, tt.schedule)
+
+			if v.Valid() != tt.wantValid {
+				t.Errorf("Valid = %t; want %t (errors: %v)", v.Valid(), tt.wantValid, v.Errors)
+			}
+		})
+	}
+}
+
+func TestScheduleIntervals(t *testing.T) {
+	tests := []struct {
+		name     string
+		schedule Schedule
+		from, to time.Time
+		want     []interval
+	}{
+		{
+			"day shifts",
+			Schedule{shift(1, "09:00", "17:00"), shift(2, "09:00", "17:00")},
+			at(4, "00:00"), at(6, "00:00"),
+			[]interval{{at(4, "09:00"), at(4, "17:00")}, {at(5, "09:00"), at(5, "17:00")}},
+		},
+		{
+			"saturday night into sunday",
+			Schedule{shift(6, "22:00", "06:00")},
+			at(2, "00:00"), at(4, "00:00"),
+			[]interval{{at(2, "22:00"), at(3, "06:00")}},
+		},
+		{
+			"carried over from the day before from",
+			Schedule{shift(6, "22:00", "06:00")},
+			at(3, "00:00"), at(4, "00:00"),
+			[]interval{{at(3, "00:00"), at(3, "06:00")}},
+		},
+		{
+			"clipped at to",
+			Schedule{shift(0, "22:00", "06:00")},
+			at(3, "00:00"), at(3, "23:00"),
+			[]interval{{at(3, "22:00"), at(3, "23:00")}},
+		},
+		{
+			"touching shifts merged",
+			Schedule{shift(1, "22:00", "06:00"), shift(2, "06:00", "09:00")},
+			at(4, "00:00"), at(6, "00:00"),
+			[]interval{{at(4, "22:00"), at(5, "09:00")}},
+		},
+		{
+			"days off",
+			Schedule{shift(3, "09:00", "17:00")},
+			at(3, "00:00"), at(6, "00:00"),
+			[]interval{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.schedule.Intervals(tt.from, tt.to)
+
+			if !equalIntervals(got, tt.want) {
+				t.Errorf("Intervals = %v; want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScheduleCovers(t *testing.T) {
+	overnight := Schedule{shift(1, "22:00", "06:00"), shift(2, "06:00", "09:00")}
+	split := Schedule{shift(1, "08:00", "12:00"), shift(1, "13:00", "17:00")}
+	weekend := Schedule{shift(6, "22:00", "06:00")}
+
+	tests := []struct {
+		name       string
+		schedule   Schedule
+		start, end time.Time
+		want       bool
+	}{
+		{"across midnight", overnight, at(4, "23:00"), at(5, "01:00"), true},
+		{"across merged shifts", overnight, at(5, "05:00"), at(5, "08:00"), true},
+		{"whole period", overnight, at(4, "22:00"), at(5, "09:00"), true},
+		{"starting before the shift", overnight, at(4, "21:00"), at(4, "23:00"), false},
+		{"ending after the shift", overnight, at(5, "08:30"), at(5, "09:30"), false},
+		{"across a gap", split, at(4, "11:00"), at(4, "14:00"), false},
+		{"saturday into sunday", weekend, at(2, "23:00"), at(3, "01:00"), true},
+		{"sunday morning", weekend, at(3, "05:00"), at(3, "06:00"), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.schedule.Covers(tt.start, tt.end); got != tt.want {
+				t.Errorf("Covers = %t; want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScheduleOnDuty(t *testing.T) {
+	// Saturday 22:00 to Sunday 06:00.
+	s := Schedule{shift(6, "22:00", "06:00")}
+
+	const grace = 15 * time.Minute
+
+	tests := []struct {
+		name            string
+		t               time.Time
+		wantOnDuty      bool
+		wantWithinGrace bool
+	}{
+		{"start of shift", at(2, "22:00"), true, true},
+		{"midnight", at(3, "00:00"), true, true},
+		{"last minute", at(3, "05:59"), true, true},
+		{"end of shift", at(3, "06:00"), false, true},
+		{"just before the shift", at(2, "21:59"), false, true},
+		{"inside grace before", at(2, "21:46"), false, true},
+		{"grace boundary before", at(2, "21:45"), false, false},
+		{"inside grace after", at(3, "06:14"), false, true},
+		{"grace boundary after", at(3, "06:15"), false, false},
+		{"day off", at(4, "12:00"), false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := s.OnDuty(tt.t); got != tt.wantOnDuty {
+				t.Errorf("OnDuty = %t; want %t", got, tt.wantOnDuty)
+			}
+
+			if got := s.OnDutyWithin(tt.t, grace); got != tt.wantWithinGrace {
+				t.Errorf("OnDutyWithin = %t; want %t", got, tt.wantWithinGrace)
+			}
+
+			if got := s.OnDutyWithin(tt.t, 0); got != tt.wantOnDuty {
+				t.Errorf("OnDutyWithin without grace = %t; want %t", got, tt.wantOnDuty)
+			}
+		})
+	}
+}
+
+func equalIntervals(a, b []interval) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if !a[i].start.Equal(b[i].start) || !a[i].end.Equal(b[i].end) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -3,9 +3,12 @@ package data
 import (
 	"context"
 	"crypto/rand"
+	"crypto/sha256"
 	"database/sql"
 	"encoding/base32"
+	"encoding/hex"
 	"errors"
+	"fmt"
 	"time"
 
 	"github.com/0xMishra/makerble/internal/validator"
@@ -14,14 +17,23 @@ import (
 const (
 	ScopeActivation     = "activation"
 	ScopeAuthentication = "authentication"
+	ScopeInvitation     = "invitation"
+	ScopeRefresh        = "refresh"
+	ScopePasswordReset  = "password-reset"
 )
 
+// ErrTokenReused is returned when a refresh token that has already been
+// rotated is presented again, which means it has most likely been stolen.
+var ErrTokenReused = errors.New("refresh token reused")
+
 type Token struct {
-	Hash   string    `json:"token"`
-	Email  string    `json:"email"`
-	Role   string    `json:"role"`
-	Expiry time.Time `json:"expiry"`
-	Scope  string    `json:"-"`
+	Plaintext string    `json:"token"`
+	Hash      []byte    `json:"-"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role"`
+	Expiry    time.Time `json:"expiry"`
+	Scope     string    `json:"-"`
+	Family    string    `json:"-"`
 }
 
 func generateToken(ttl time.Duration, email, role, scope string) (*Token, error) {
@@ -41,11 +53,19 @@ func generateToken(ttl time.Duration, email, role, scope string) (*Token, error)
 	}
 
 	// padding is = at the end of the token that we are avoiding here
-	token.Hash = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(randomBytes)
+	token.Plaintext = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(randomBytes)
+
+	// only the digest is ever stored, the plaintext is handed to the client once
+	token.Hash = hashToken(token.Plaintext)
 
 	return token, nil
 }
 
+func hashToken(tokenPlaintext string) []byte {
+	hash := sha256.Sum256([]byte(tokenPlaintext))
+	return hash[:]
+}
+
 func ValidateTokenPlaintext(v *validator.Validator, tokenPlaintext string) {
 	v.Check(tokenPlaintext != "", "token", "must be provided")
 	v.Check(len(tokenPlaintext) == 42, "token", "must be 42 bytes long")
@@ -66,11 +86,11 @@ func (m TokenModel) New(ttl time.Duration, email, role, scope string) (*Token, e
 
 func (m TokenModel) Insert(token *Token) error {
 	query := `
-		INSERT INTO tokens (hash, email, role, expiry, scope)
-		VALUES ($1, $2, $3, $4, $5)
+		INSERT INTO tokens (hash, email, role, expiry, scope, family)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''))
 	`
 
-	args := []any{token.Hash, token.Email, token.Role, token.Expiry, token.Scope}
+	args := []any{token.Hash, token.Email, token.Role, token.Expiry, token.Scope, token.Family}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
@@ -79,35 +99,242 @@ func (m TokenModel) Insert(token *Token) error {
 	return err
 }
 
-func (m TokenModel) DeleteAllForUser(scope string, email string) error {
+// DeleteAllForUser deletes the tokens with scope of the account registered
+// under email for role. The same email may belong to accounts of other roles,
+// whose tokens are left alone.
+func (m TokenModel) DeleteAllForUser(scope, email, role string) error {
 	query := `
 		DELETE FROM tokens
-		WHERE scope = $1 AND email = $2
+		WHERE scope = $1 AND email = $2 AND role = $3
 	`
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	_, err := m.DB.ExecContext(ctx, query, scope, email)
+	_, err := m.DB.ExecContext(ctx, query, scope, email, role)
 
 	return err
 }
 
-func (m TokenModel) GetUserForToken(hash string) (*Token, error) {
+func (m TokenModel) GetUserForToken(scope, tokenPlaintext string) (*Token, error) {
 	query := `
-		SELECT email, role, expiry FROM tokens
-		WHERE hash = $1
+		SELECT email, role, expiry, scope, COALESCE(family, '') FROM tokens
+		WHERE hash = $1 AND scope = $2
 	`
 
 	var t Token
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	err := m.DB.QueryRowContext(ctx, query, hash).Scan(
+	err := m.DB.QueryRowContext(ctx, query, hashToken(tokenPlaintext), scope).Scan(
 		&t.Email,
 		&t.Role,
 		&t.Expiry,
+		&t.Scope,
+		&t.Family,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+
+		default:
+			return nil, err
+		}
+	}
+
+	return &t, nil
+}
+
+// NewPair issues an authentication token and a refresh token for a new login.
+// Both start a token family, recorded as a session with the client's user
+// agent and IP, that later rotations stay in.
+func (m TokenModel) NewPair(accessTTL, refreshTTL time.Duration, email, role, userAgent, ip string) (access, refresh *Token, err error) {
+	familyBytes := make([]byte, 16)
+
+	_, err = rand.Read(familyBytes)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	family := hex.EncodeToString(familyBytes)
+
+	query := `
+		INSERT INTO sessions (family, email, role, user_agent, ip)
+		VALUES ($1, $2, $3, $4, $5)
+	`
+
+	_, err = tx.ExecContext(ctx, query, family, email, role, userAgent, ip)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	access, refresh, err = insertPair(ctx, tx, accessTTL, refreshTTL, email, role, family)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return access, refresh, tx.Commit()
+}
+
+// Rotate exchanges a refresh token for a new pair in the same family. The
+// old refresh token is kept, marked as used, until it expires. Presenting it
+// again deletes the session, and with it every token in the family, and
+// returns ErrTokenReused.
+func (m TokenModel) Rotate(refreshPlaintext string, accessTTL, refreshTTL time.Duration) (access, refresh *Token, err error) {
+	query := `
+		SELECT email, role, expiry, family, used_at IS NOT NULL
+		FROM tokens
+		WHERE hash = $1 AND scope = $2
+		FOR UPDATE
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	var (
+		t    Token
+		used bool
 	)
+
+	err = tx.QueryRowContext(ctx, query, hashToken(refreshPlaintext), ScopeRefresh).Scan(&t.Email, &t.Role, &t.Expiry, &t.Family, &used)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, nil, ErrRecordNotFound
+
+		default:
+			return nil, nil, err
+		}
+	}
+
+	if used {
+		_, err = tx.ExecContext(ctx, `DELETE FROM sessions WHERE family = $1`, t.Family)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return nil, nil, ErrTokenReused
+	}
+
+	if time.Now().After(t.Expiry) {
+		return nil, nil, ErrRecordNotFound
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE tokens SET used_at = NOW() WHERE hash = $1`, hashToken(refreshPlaintext))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE sessions SET last_used_at = NOW() WHERE family = $1`, t.Family)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	access, refresh, err = insertPair(ctx, tx, accessTTL, refreshTTL, t.Email, t.Role, t.Family)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return access, refresh, tx.Commit()
+}
+
+func insertPair(ctx context.Context, tx *sql.Tx, accessTTL, refreshTTL time.Duration, email, role, family string) (access, refresh *Token, err error) {
+	query := `
+		INSERT INTO tokens (hash, email, role, expiry, scope, family)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`
+
+	access, err = generateToken(accessTTL, email, role, ScopeAuthentication)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	refresh, err = generateToken(refreshTTL, email, role, ScopeRefresh)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, t := range []*Token{access, refresh} {
+		t.Family = family
+
+		_, err = tx.ExecContext(ctx, query, t.Hash, t.Email, t.Role, t.Expiry, t.Scope, t.Family)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return access, refresh, nil
+}
+
+// RevokeAllForUser deletes every session of the account registered under
+// email for role, and with them all its authentication and refresh tokens,
+// signing the user out everywhere.
+func (m TokenModel) RevokeAllForUser(email, role string) error {
+	query := `
+		DELETE FROM sessions
+		WHERE email = $1 AND role = $2
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, email, role)
+
+	return err
+}
+
+// accountTables maps every role that has accounts to the table holding them.
+var accountTables = map[string]string{
+	"doctor":       "doctors",
+	"receptionist": "receptionists",
+	"admin":        "admins",
+}
+
+// ResetPassword consumes the password reset token and sets the password hash
+// of its account in one transaction, so a token can't be used twice even by
+// concurrent requests. The account's other reset and two-factor tokens are
+// deleted along with its sessions, signing the user out everywhere. It fails
+// with ErrRecordNotFound when the token is unknown or has expired.
+func (m TokenModel) ResetPassword(tokenPlaintext string, passwordHash []byte) (*Token, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `
+		DELETE FROM tokens
+		WHERE hash = $1 AND scope = $2 AND expiry > NOW()
+		RETURNING email, role, expiry
+	`
+
+	t := Token{Scope: ScopePasswordReset}
+
+	err = tx.QueryRowContext(ctx, query, hashToken(tokenPlaintext), ScopePasswordReset).Scan(&t.Email, &t.Role, &t.Expiry)
 	if err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
@@ -118,5 +345,76 @@ func (m TokenModel) GetUserForToken(hash string) (*Token, error) {
 		}
 	}
 
+	table, ok := accountTables[t.Role]
+	if !ok {
+		return nil, ErrRecordNotFound
+	}
+
+	query = fmt.Sprintf(`
+		UPDATE %s
+		SET password_hash = $1, version = version + 1
+		WHERE email = $2
+	`, table)
+
+	result, err := tx.ExecContext(ctx, query, passwordHash, t.Email)
+	if err != nil {
+		return nil, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+
+	if rowsAffected == 0 {
+		return nil, ErrRecordNotFound
+	}
+
+	query = `
+		DELETE FROM tokens
+		WHERE email = $1 AND role = $2 AND scope IN ($3, $4)
+	`
+
+	_, err = tx.ExecContext(ctx, query, t.Email, t.Role, ScopePasswordReset, ScopeTwoFactor)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM sessions WHERE email = $1 AND role = $2`, t.Email, t.Role)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
 	return &t, nil
 }
+
+// DeleteExpired removes the tokens that have expired, and the sessions left
+// without any token, and returns how many tokens were removed.
+func (m TokenModel) DeleteExpired(ctx context.Context) (int64, error) {
+	result, err := m.DB.ExecContext(ctx, `DELETE FROM tokens WHERE expiry < NOW()`)
+	if err != nil {
+		return 0, err
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	query := `
+		DELETE FROM sessions s
+		WHERE NOT EXISTS (SELECT 1 FROM tokens t WHERE t.family = s.family)
+	`
+
+	_, err = m.DB.ExecContext(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+
+	return deleted, nil
+}
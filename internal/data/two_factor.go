@@ -0,0 +1,321 @@
+package data
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/0xMishra/makerble/internal/validator"
+)
+
+const ScopeTwoFactor = "two-factor"
+
+// recoveryCodeCount is how many recovery codes are handed out when two-factor
+// authentication is enabled.
+const recoveryCodeCount = 10
+
+var ErrTwoFactorEnabled = errors.New("two-factor authentication already enabled")
+
+// TwoFactor is the TOTP secret of an account. It only protects logins once
+// ConfirmedAt is set.
+type TwoFactor struct {
+	Email        string
+	Role         string
+	Secret       []byte
+	ConfirmedAt  *time.Time
+	LastUsedStep int64
+}
+
+func ValidateTOTPCode(v *validator.Validator, code string) {
+	v.Check(code != "", "code", "must be provided")
+	v.Check(len(code) == 6, "code", "must be 6 digits long")
+	v.Check(isDigits(code), "code", "must only contain digits")
+}
+
+// generateRecoveryCodes returns recovery codes of the form xxxxx-xxxxx
+// together with their hashes. Only the hashes are stored.
+func generateRecoveryCodes(n int) ([]string, [][]byte, error) {
+	codes := make([]string, 0, n)
+	hashes := make([][]byte, 0, n)
+
+	for range n {
+		randomBytes := make([]byte, 7)
+
+		_, err := rand.Read(randomBytes)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		code := strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(randomBytes))[:10]
+		code = code[:5] + "-" + code[5:]
+
+		codes = append(codes, code)
+		hashes = append(hashes, hashRecoveryCode(code))
+	}
+
+	return codes, hashes, nil
+}
+
+// hashRecoveryCode ignores case and the dash, which people tend to get wrong
+// when typing a code in.
+func hashRecoveryCode(code string) []byte {
+	code = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	return hashToken(code)
+}
+
+type TwoFactorModel struct {
+	DB *sql.DB
+}
+
+func (m TwoFactorModel) Get(email, role string) (*TwoFactor, error) {
+	query := `
+		SELECT email, role, secret, confirmed_at, last_used_step
+		FROM totp_credentials
+		WHERE email = $1 AND role = $2
+	`
+
+	var tf TwoFactor
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, email, role).Scan(&tf.Email, &tf.Role, &tf.Secret, &tf.ConfirmedAt, &tf.LastUsedStep)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+
+		default:
+			return nil, err
+		}
+	}
+
+	return &tf, nil
+}
+
+// Enabled reports whether the account has confirmed two-factor
+// authentication.
+func (m TwoFactorModel) Enabled(email, role string) (bool, error) {
+	query := `
+		SELECT EXISTS (
+			SELECT 1 FROM totp_credentials
+			WHERE email = $1 AND role = $2 AND confirmed_at IS NOT NULL
+		)
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var enabled bool
+
+	err := m.DB.QueryRowContext(ctx, query, email, role).Scan(&enabled)
+	return enabled, err
+}
+
+// Begin stores a new, unconfirmed secret for the account, replacing any
+// earlier one that was never confirmed.
+func (m TwoFactorModel) Begin(email, role string, secret []byte) error {
+	query := `
+		INSERT INTO totp_credentials (email, role, secret)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (email, role) DO UPDATE
+		SET secret = EXCLUDED.secret, created_at = NOW(), last_used_step = 0
+		WHERE totp_credentials.confirmed_at IS NULL
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, email, role, secret)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrTwoFactorEnabled
+	}
+
+	return nil
+}
+
+// Confirm enables two-factor authentication after the user entered the code
+// for step, and returns a fresh set of recovery codes.
+func (m TwoFactorModel) Confirm(email, role string, step int64) ([]string, error) {
+	codes, hashes, err := generateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `
+		UPDATE totp_credentials
+		SET confirmed_at = NOW(), last_used_step = $3
+		WHERE email = $1 AND role = $2 AND confirmed_at IS NULL
+	`
+
+	result, err := tx.ExecContext(ctx, query, email, role, step)
+	if err != nil {
+		return nil, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+
+	if rowsAffected == 0 {
+		return nil, ErrTwoFactorEnabled
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM totp_recovery_codes WHERE email = $1 AND role = $2`, email, role)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, hash := range hashes {
+		_, err = tx.ExecContext(ctx, `INSERT INTO totp_recovery_codes (email, role, hash) VALUES ($1, $2, $3)`, email, role, hash)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return codes, tx.Commit()
+}
+
+// UseStep records that a code for step was accepted. It reports false when a
+// code for that step or a later one has already been used, which stops the
+// same code being replayed by someone watching over the user's shoulder.
+func (m TwoFactorModel) UseStep(email, role string, step int64) (bool, error) {
+	query := `
+		UPDATE totp_credentials
+		SET last_used_step = $3
+		WHERE email = $1 AND role = $2 AND last_used_step < $3
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, email, role, step)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rowsAffected == 1, nil
+}
+
+// UseRecoveryCode spends one of the account's recovery codes. It reports
+// false when the code is unknown or has been used before.
+func (m TwoFactorModel) UseRecoveryCode(email, role, code string) (bool, error) {
+	query := `
+		UPDATE totp_recovery_codes
+		SET used_at = NOW()
+		WHERE email = $1 AND role = $2 AND hash = $3 AND used_at IS NULL
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, email, role, hashRecoveryCode(code))
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rowsAffected > 0, nil
+}
+
+// Delete turns two-factor authentication off for the account, along with its
+// recovery codes.
+func (m TwoFactorModel) Delete(email, role string) error {
+	query := `
+		DELETE FROM totp_credentials
+		WHERE email = $1 AND role = $2
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, email, role)
+	return err
+}
+
+// ChangeEmail moves the account's secret and recovery codes to its new email
+// address.
+func (m TwoFactorModel) ChangeEmail(role, previousEmail, email string) error {
+	query := `
+		UPDATE totp_credentials
+		SET email = $3
+		WHERE email = $1 AND role = $2
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, previousEmail, role, email)
+	return err
+}
+
+// RequiredForRole reports whether an admin has made two-factor
+// authentication mandatory for the role.
+func (m TwoFactorModel) RequiredForRole(role string) (bool, error) {
+	query := `
+		SELECT EXISTS (SELECT 1 FROM two_factor_roles WHERE role = $1)
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var required bool
+
+	err := m.DB.QueryRowContext(ctx, query, role).Scan(&required)
+	return required, err
+}
+
+func (m TwoFactorModel) SetRequiredForRole(role string, required bool, by string) error {
+	query := `
+		DELETE FROM two_factor_roles
+		WHERE role = $1
+	`
+	args := []any{role}
+
+	if required {
+		query = `
+			INSERT INTO two_factor_roles (role, required_by)
+			VALUES ($1, $2)
+			ON CONFLICT (role) DO NOTHING
+		`
+		args = append(args, by)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, args...)
+	return err
+}
@@ -0,0 +1,19 @@
+package data
+
+// User is the role-independent view of an authenticated doctor or
+// receptionist that is carried in the request context.
+type User struct {
+	ID        int64  `json:"id"`
+	Name      string `json:"name"`
+	Email     string `json:"email"`
+	Role      string `json:"role"`
+	Activated bool   `json:"activated"`
+}
+
+// AnonymousUser is placed in the request context when no Authorization header
+// is sent.
+var AnonymousUser = &User{}
+
+func (u *User) IsAnonymous() bool {
+	return u == AnonymousUser
+}
@@ -0,0 +1,248 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// jsonEqual compares two JSON documents by value, ignoring member order and
+// whitespace.
+func jsonEqual(t *testing.T, a, b []byte) bool {
+	t.Helper()
+
+	var x, y any
+
+	if err := json.Unmarshal(a, &x); err != nil {
+		t.Fatalf("invalid JSON %s: %v", a, err)
+	}
+	if err := json.Unmarshal(b, &y); err != nil {
+		t.Fatalf("invalid JSON %s: %v", b, err)
+	}
+
+	return reflect.DeepEqual(x, y)
+}
+
+func TestApply(t *testing.T) {
+	// Unless noted otherwise the cases are the examples of RFC 6902,
+	// appendix A.
+	tests := []struct {
+		name  string
+		doc   string
+		patch string
+		want  string
+	}{
+		{
+			"A.1 adding an object member",
+			`{"foo": "bar"}`,
+			`[{"op": "add", "path": "/baz", "value": "qux"}]`,
+			`{"baz": "qux", "foo": "bar"}`,
+		},
+		{
+			"A.2 adding an array element",
+			`{"foo": ["bar", "baz"]}`,
+			`[{"op": "add", "path": "/foo/1", "value": "qux"}]`,
+			`{"foo": ["bar", "qux", "baz"]}`,
+		},
+		{
+			"A.3 removing an object member",
+			`{"baz": "qux", "foo": "bar"}`,
+			`[{"op": "remove", "path": "/baz"}]`,
+			`{"foo": "bar"}`,
+		},
+		{
+			"A.4 removing an array element",
+			`{"foo": ["bar", "qux", "baz"]}`,
+			`[{"op": "remove", "path": "/foo/1"}]`,
+			`{"foo": ["bar", "baz"]}`,
+		},
+		{
+			"A.5 replacing a value",
+			`{"baz": "qux", "foo": "bar"}`,
+			`[{"op": "replace", "path": "/baz", "value": "boo"}]`,
+			`{"baz": "boo", "foo": "bar"}`,
+		},
+		{
+			"A.6 moving a value",
+			`{"foo": {"bar": "baz", "waldo": "fred"}, "qux": {"corge": "grault"}}`,
+			`[{"op": "move", "from": "/foo/waldo", "path": "/qux/thud"}]`,
+			`{"foo": {"bar": "baz"}, "qux": {"corge": "grault", "thud": "fred"}}`,
+		},
+		{
+			"A.7 moving an array element",
+			`{"foo": ["all", "grass", "cows", "eat"]}`,
+			`[{"op": "move", "from": "/foo/1", "path": "/foo/3"}]`,
+			`{"foo": ["all", "cows", "eat", "grass"]}`,
+		},
+		{
+			"A.8 testing a value: success",
+			`{"baz": "qux", "foo": ["a", 2, "c"]}`,
+			`[{"op": "test", "path": "/baz", "value": "qux"}, {"op": "test", "path": "/foo/1", "value": 2}]`,
+			`{"baz": "qux", "foo": ["a", 2, "c"]}`,
+		},
+		{
+			"A.10 adding a nested member object",
+			`{"foo": "bar"}`,
+			`[{"op": "add", "path": "/child", "value": {"grandchild": {}}}]`,
+			`{"foo": "bar", "child": {"grandchild": {}}}`,
+		},
+		{
+			"A.11 ignoring unrecognized elements",
+			`{"foo": "bar"}`,
+			`[{"op": "add", "path": "/baz", "value": "qux", "xyz": 123}]`,
+			`{"foo": "bar", "baz": "qux"}`,
+		},
+		{
+			"A.14 ~ escape ordering",
+			`{"/": 9, "~1": 10}`,
+			`[{"op": "test", "path": "/~01", "value": 10}]`,
+			`{"/": 9, "~1": 10}`,
+		},
+		{
+			"A.16 adding an array value",
+			`{"foo": ["bar"]}`,
+			`[{"op": "add", "path": "/foo/-", "value": ["abc", "def"]}]`,
+			`{"foo": ["bar", ["abc", "def"]]}`,
+		},
+		{
+			"copying a value",
+			`{"foo": {"bar": 1}}`,
+			`[{"op": "copy", "from": "/foo", "path": "/baz"}, {"op": "replace", "path": "/baz/bar", "value": 2}]`,
+			`{"foo": {"bar": 1}, "baz": {"bar": 2}}`,
+		},
+		{
+			"~1 in a path",
+			`{"a/b": 1}`,
+			`[{"op": "replace", "path": "/a~1b", "value": 2}]`,
+			`{"a/b": 2}`,
+		},
+		{
+			"numbers compared by value",
+			`{"n": 1.0}`,
+			`[{"op": "test", "path": "/n", "value": 1}]`,
+			`{"n": 1.0}`,
+		},
+		{
+			"replacing the whole document",
+			`{"foo": "bar"}`,
+			`[{"op": "replace", "path": "", "value": [1]}]`,
+			`[1]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Apply([]byte(tt.doc), []byte(tt.patch))
+			if err != nil {
+				t.Fatalf("Apply: %v", err)
+			}
+
+			if !jsonEqual(t, got, []byte(tt.want)) {
+				t.Errorf("Apply = %s; want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		doc   string
+		patch string
+		want  error
+	}{
+		{"A.9 testing a value: error", `{"baz": "qux"}`, `[{"op": "test", "path": "/baz", "value": "bar"}]`, ErrConflict},
+		{"A.12 adding to a nonexistent target", `{"foo": "bar"}`, `[{"op": "add", "path": "/baz/bat", "value": "qux"}]`, ErrConflict},
+		{"A.15 comparing strings and numbers", `{"/": 9, "~1": 10}`, `[{"op": "test", "path": "/~01", "value": "10"}]`, ErrConflict},
+		{"removing a missing member", `{"foo": "bar"}`, `[{"op": "remove", "path": "/baz"}]`, ErrConflict},
+		{"replacing a missing member", `{"foo": "bar"}`, `[{"op": "replace", "path": "/baz", "value": 1}]`, ErrConflict},
+		{"index out of range", `{"foo": [1]}`, `[{"op": "add", "path": "/foo/2", "value": 1}]`, ErrConflict},
+		{"index with a leading zero", `{"foo": [1, 2]}`, `[{"op": "remove", "path": "/foo/01"}]`, ErrConflict},
+		{"- outside of add", `{"foo": [1]}`, `[{"op": "remove", "path": "/foo/-"}]`, ErrConflict},
+		{"moving into a child", `{"foo": {"bar": 1}}`, `[{"op": "move", "from": "/foo", "path": "/foo/bar/baz"}]`, ErrConflict},
+		{"not an array", `{}`, `{"op": "add"}`, ErrMalformed},
+		{"unknown operation", `{}`, `[{"op": "frobnicate", "path": "/a"}]`, ErrMalformed},
+		{"missing path", `{}`, `[{"op": "add", "value": 1}]`, ErrMalformed},
+		{"missing value", `{}`, `[{"op": "add", "path": "/a"}]`, ErrMalformed},
+		{"missing from", `{"a": 1}`, `[{"op": "move", "path": "/b"}]`, ErrMalformed},
+		{"path without a leading slash", `{}`, `[{"op": "add", "path": "a", "value": 1}]`, ErrMalformed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Apply([]byte(tt.doc), []byte(tt.patch))
+			if !errors.Is(err, tt.want) {
+				t.Errorf("Apply error = %v; want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyIsAtomic(t *testing.T) {
+	doc := []byte(`{"foo": ["bar"], "baz": "qux"}`)
+	original := string(doc)
+
+	patch := []byte(`[
+		{"op": "add", "path": "/foo/-", "value": "added"},
+		{"op": "remove", "path": "/baz"},
+		{"op": "test", "path": "/foo/0", "value": "not bar"}
+	]`)
+
+	got, err := Apply(doc, patch)
+	if !errors.Is(err, ErrConflict) {
+		t.Fatalf("Apply error = %v; want %v", err, ErrConflict)
+	}
+
+	if got != nil {
+		t.Errorf("Apply returned %s alongside an error; want nothing", got)
+	}
+
+	if string(doc) != original {
+		t.Errorf("document changed to %s; want it left as %s", doc, original)
+	}
+}
+
+func TestMergePatch(t *testing.T) {
+	// The examples of RFC 7396, appendix A.
+	tests := []struct {
+		doc   string
+		patch string
+		want  string
+	}{
+		{`{"a": "b"}`, `{"a": "c"}`, `{"a": "c"}`},
+		{`{"a": "b"}`, `{"b": "c"}`, `{"a": "b", "b": "c"}`},
+		{`{"a": "b"}`, `{"a": null}`, `{}`},
+		{`{"a": "b", "b": "c"}`, `{"a": null}`, `{"b": "c"}`},
+		{`{"a": ["b"]}`, `{"a": "c"}`, `{"a": "c"}`},
+		{`{"a": "c"}`, `{"a": ["b"]}`, `{"a": ["b"]}`},
+		{`{"a": {"b": "c"}}`, `{"a": {"b": "d", "c": null}}`, `{"a": {"b": "d"}}`},
+		{`{"a": [{"b": "c"}]}`, `{"a": [1]}`, `{"a": [1]}`},
+		{`["a", "b"]`, `["c", "d"]`, `["c", "d"]`},
+		{`{"a": "b"}`, `["c"]`, `["c"]`},
+		{`{"a": "foo"}`, `null`, `null`},
+		{`{"a": "foo"}`, `"bar"`, `"bar"`},
+		{`{"e": null}`, `{"a": 1}`, `{"e": null, "a": 1}`},
+		{`[1, 2]`, `{"a": "b", "c": null}`, `{"a": "b"}`},
+		{`{}`, `{"a": {"bb": {"ccc": null}}}`, `{"a": {"bb": {}}}`},
+	}
+
+	for _, tt := range tests {
+		got, err := MergePatch([]byte(tt.doc), []byte(tt.patch))
+		if err != nil {
+			t.Errorf("MergePatch(%s, %s): %v", tt.doc, tt.patch, err)
+			continue
+		}
+
+		if !jsonEqual(t, got, []byte(tt.want)) {
+			t.Errorf("MergePatch(%s, %s) = %s; want %s", tt.doc, tt.patch, got, tt.want)
+		}
+	}
+}
+
+func TestMergePatchMalformed(t *testing.T) {
+	_, err := MergePatch([]byte(`{}`), []byte(`{"a": `))
+	if !errors.Is(err, ErrMalformed) {
+		t.Errorf("MergePatch error = %v; want %v", err, ErrMalformed)
+	}
+}
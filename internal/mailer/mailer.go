@@ -0,0 +1,150 @@
+// Package mailer renders and delivers the emails sent by the API
+package mailer
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"html/template"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	ttemplate "text/template"
+	"time"
+)
+
+//go:embed "templates"
+var templateFS embed.FS
+
+// Message is a rendered email that is ready to be handed to a Transport.
+type Message struct {
+	Sender    string
+	Recipient string
+	Subject   string
+	PlainBody string
+	HTMLBody  string
+}
+
+type Mailer struct {
+	transport Transport
+	sender    string
+	retries   int
+	backoff   time.Duration
+}
+
+// New returns a Mailer which delivers through transport. A failed delivery is
+// attempted up to retries more times, waiting backoff before the first retry
+// and doubling the wait after every further failure.
+func New(transport Transport, sender string, retries int, backoff time.Duration) Mailer {
+	return Mailer{
+		transport: transport,
+		sender:    sender,
+		retries:   retries,
+		backoff:   backoff,
+	}
+}
+
+// Send renders the "subject", "plainBody" and "htmlBody" templates defined in
+// templateFile with data and delivers the result to recipient.
+func (m Mailer) Send(recipient, templateFile string, data any) error {
+	msg, err := m.render(recipient, templateFile, data)
+	if err != nil {
+		return err
+	}
+
+	wait := m.backoff
+
+	for i := 0; ; i++ {
+		err = m.transport.Deliver(msg)
+		if err == nil || i >= m.retries {
+			break
+		}
+
+		time.Sleep(wait)
+		wait *= 2
+	}
+
+	return err
+}
+
+func (m Mailer) render(recipient, templateFile string, data any) (Message, error) {
+	textTmpl, err := ttemplate.New("email").ParseFS(templateFS, "templates/"+templateFile)
+	if err != nil {
+		return Message{}, err
+	}
+
+	subject := new(bytes.Buffer)
+	err = textTmpl.ExecuteTemplate(subject, "subject", data)
+	if err != nil {
+		return Message{}, err
+	}
+
+	plainBody := new(bytes.Buffer)
+	err = textTmpl.ExecuteTemplate(plainBody, "plainBody", data)
+	if err != nil {
+		return Message{}, err
+	}
+
+	htmlTmpl, err := template.New("email").ParseFS(templateFS, "templates/"+templateFile)
+	if err != nil {
+		return Message{}, err
+	}
+
+	htmlBody := new(bytes.Buffer)
+	err = htmlTmpl.ExecuteTemplate(htmlBody, "htmlBody", data)
+	if err != nil {
+		return Message{}, err
+	}
+
+	msg := Message{
+		Sender:    m.sender,
+		Recipient: recipient,
+		Subject:   subject.String(),
+		PlainBody: plainBody.String(),
+		HTMLBody:  htmlBody.String(),
+	}
+
+	return msg, nil
+}
+
+// Bytes encodes the message as a multipart/alternative RFC 5322 email.
+func (msg Message) Bytes() ([]byte, error) {
+	body := new(bytes.Buffer)
+	mw := multipart.NewWriter(body)
+
+	parts := []struct {
+		contentType string
+		content     string
+	}{
+		{"text/plain; charset=UTF-8", msg.PlainBody},
+		{"text/html; charset=UTF-8", msg.HTMLBody},
+	}
+
+	for _, p := range parts {
+		pw, err := mw.CreatePart(textproto.MIMEHeader{"Content-Type": {p.contentType}})
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = pw.Write([]byte(p.content))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err := mw.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	b := new(bytes.Buffer)
+	fmt.Fprintf(b, "From: %s\r\n", msg.Sender)
+	fmt.Fprintf(b, "To: %s\r\n", msg.Recipient)
+	fmt.Fprintf(b, "Subject: %s\r\n", mime.QEncoding.Encode("UTF-8", msg.Subject))
+	fmt.Fprintf(b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(b, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(b, "Content-Type: multipart/alternative; boundary=%q\r\n\r\n", mw.Boundary())
+	b.Write(body.Bytes())
+
+	return b.Bytes(), nil
+}
@@ -0,0 +1,124 @@
+package mailer
+
+import (
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Transport delivers a rendered message.
+type Transport interface {
+	Deliver(msg Message) error
+}
+
+// SMTPTransport delivers messages to an SMTP server.
+type SMTPTransport struct {
+	addr string
+	auth smtp.Auth
+}
+
+func NewSMTPTransport(host string, port int, username, password string) *SMTPTransport {
+	t := &SMTPTransport{
+		addr: net.JoinHostPort(host, strconv.Itoa(port)),
+	}
+
+	// Local SMTP stand-ins such as MailHog or Mailpit accept mail without
+	// authentication, so credentials are only used when they are configured.
+	if username != "" {
+		t.auth = smtp.PlainAuth("", username, password, host)
+	}
+
+	return t
+}
+
+func (t *SMTPTransport) Deliver(msg Message) error {
+	from, err := mail.ParseAddress(msg.Sender)
+	if err != nil {
+		return err
+	}
+
+	b, err := msg.Bytes()
+	if err != nil {
+		return err
+	}
+
+	return smtp.SendMail(t.addr, t.auth, from.Address, []string{msg.Recipient}, b)
+}
+
+// FileTransport drops every message into a directory as an .eml file instead
+// of sending it.
+type FileTransport struct {
+	dir string
+}
+
+func NewFileTransport(dir string) *FileTransport {
+	return &FileTransport{dir: dir}
+}
+
+func (t *FileTransport) Deliver(msg Message) error {
+	err := os.MkdirAll(t.dir, 0o755)
+	if err != nil {
+		return err
+	}
+
+	b, err := msg.Bytes()
+	if err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%d-%s.eml", time.Now().UnixNano(), safeFileName(msg.Recipient))
+
+	return os.WriteFile(filepath.Join(t.dir, name), b, 0o644)
+}
+
+// safeFileName replaces everything but letters, digits, dots and hyphens in
+// an address with underscores, so that it can't name a path outside the
+// directory or a character the file system doesn't allow.
+func safeFileName(address string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-':
+			return r
+		default:
+			return '_'
+		}
+	}, address)
+}
+
+// MemoryTransport keeps delivered messages in memory so they can be
+// inspected without a network.
+type MemoryTransport struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+func NewMemoryTransport() *MemoryTransport {
+	return &MemoryTransport{}
+}
+
+func (t *MemoryTransport) Deliver(msg Message) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.messages = append(t.messages, msg)
+
+	return nil
+}
+
+// Messages returns a copy of every message delivered so far.
+func (t *MemoryTransport) Messages() []Message {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	messages := make([]Message, len(t.messages))
+	copy(messages, t.messages)
+
+	return messages
+}
@@ -0,0 +1,241 @@
+package mailer
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+var errUnavailable = errors.New("smtp server unavailable")
+
+// flakyTransport fails the first failures deliveries and records when each
+// delivery was attempted.
+type flakyTransport struct {
+	mu        sync.Mutex
+	failures  int
+	attempts  []time.Time
+	delivered *MemoryTransport
+}
+
+func (t *flakyTransport) Deliver(msg Message) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.attempts = append(t.attempts, time.Now())
+
+	if len(t.attempts) <= t.failures {
+		return errUnavailable
+	}
+
+	return t.delivered.Deliver(msg)
+}
+
+var lockedData = map[string]any{
+	"name":    "Jane",
+	"role":    "doctor",
+	"ip":      "192.0.2.1",
+	"lockout": "15m0s",
+}
+
+func TestSendRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		failures     int
+		retries      int
+		wantAttempts int
+		wantErr      error
+	}{
+		{"delivered first time", 0, 3, 1, nil},
+		{"delivered on a retry", 2, 3, 3, nil},
+		{"delivered on the last retry", 3, 3, 4, nil},
+		{"retries exhausted", 5, 3, 4, errUnavailable},
+		{"no retries", 1, 0, 1, errUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transport := &flakyTransport{failures: tt.failures, delivered: NewMemoryTransport()}
+
+			m := New(transport, "Makerble <no-reply@makerble.test>", tt.retries, time.Millisecond)
+
+			err := m.Send("jane@example.com", "account_locked.tmpl", lockedData)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Send error = %v; want %v", err, tt.wantErr)
+			}
+
+			if len(transport.attempts) != tt.wantAttempts {
+				t.Errorf("attempts = %d; want %d", len(transport.attempts), tt.wantAttempts)
+			}
+
+			wantDelivered := 0
+			if tt.wantErr == nil {
+				wantDelivered = 1
+			}
+
+			if got := len(transport.delivered.Messages()); got != wantDelivered {
+				t.Errorf("delivered = %d; want %d", got, wantDelivered)
+			}
+		})
+	}
+}
+
+func TestSendBackoff(t *testing.T) {
+	const backoff = 20 * time.Millisecond
+
+	transport := &flakyTransport{failures: 3, delivered: NewMemoryTransport()}
+
+	m := New(transport, "Makerble <no-reply@makerble.test>", 3, backoff)
+
+	err := m.Send("jane@example.com", "account_locked.tmpl", lockedData)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(transport.attempts) != 4 {
+		t.Fatalf("attempts = %d; want 4", len(transport.attempts))
+	}
+
+	// The wait doubles after every failure. Sleeping may overshoot, so only
+	// the lower bounds are checked.
+	wait := backoff
+
+	for i := 1; i < len(transport.attempts); i++ {
+		gap := transport.attempts[i].Sub(transport.attempts[i-1])
+		if gap < wait {
+			t.Errorf("wait before attempt %d = %v; want at least %v", i+1, gap, wait)
+		}
+
+		wait *= 2
+	}
+}
+
+func TestMemoryTransport(t *testing.T) {
+	transport := NewMemoryTransport()
+
+	m := New(transport, "Makerble <no-reply@makerble.test>", 0, 0)
+
+	for _, recipient := range []string{"jane@example.com", "john@example.com"} {
+		err := m.Send(recipient, "account_locked.tmpl", lockedData)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	messages := transport.Messages()
+	if len(messages) != 2 {
+		t.Fatalf("messages = %d; want 2", len(messages))
+	}
+
+	msg := messages[0]
+
+	if msg.Recipient != "jane@example.com" || messages[1].Recipient != "john@example.com" {
+		t.Errorf("recipients = %q, %q; want them in the order sent", msg.Recipient, messages[1].Recipient)
+	}
+
+	if msg.Sender != "Makerble <no-reply@makerble.test>" {
+		t.Errorf("Sender = %q", msg.Sender)
+	}
+
+	if msg.Subject != "Your Makerble account has been locked" {
+		t.Errorf("Subject = %q", msg.Subject)
+	}
+
+	for _, body := range []string{msg.PlainBody, msg.HTMLBody} {
+		if !strings.Contains(body, "Hi Jane,") || !strings.Contains(body, "192.0.2.1") {
+			t.Errorf("body doesn't contain the template data:\n%s", body)
+		}
+	}
+
+	// Messages hands out a copy, so callers can't change what was delivered.
+	messages[0].Subject = "changed"
+
+	if transport.Messages()[0].Subject == "changed" {
+		t.Error("Messages returned the transport's own slice")
+	}
+}
+
+func TestFileTransport(t *testing.T) {
+	dir := t.TempDir()
+
+	m := New(NewFileTransport(dir), "Makerble <no-reply@makerble.test>", 0, 0)
+
+	err := m.Send("jane@example.com", "account_locked.tmpl", lockedData)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 1 || !strings.HasSuffix(entries[0].Name(), "-jane_example.com.eml") {
+		t.Fatalf("files = %v; want one .eml file for jane@example.com", entries)
+	}
+
+	b, err := os.ReadFile(filepath.Join(dir, entries[0].Name()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, header := range []string{"To: jane@example.com\r\n", "MIME-Version: 1.0\r\n", "Content-Type: multipart/alternative"} {
+		if !bytes.Contains(b, []byte(header)) {
+			t.Errorf("message is missing %q", header)
+		}
+	}
+}
+
+func TestSafeFileName(t *testing.T) {
+	tests := []struct {
+		address string
+		want    string
+	}{
+		{"jane@example.com", "jane_example.com"},
+		{"Jane.Doe-1@example.com", "Jane.Doe-1_example.com"},
+		{"../../etc/passwd@example.com", ".._.._etc_passwd_example.com"},
+		{`"a b"\c@example.com`, "_a_b__c_example.com"},
+	}
+
+	for _, tt := range tests {
+		if got := safeFileName(tt.address); got != tt.want {
+			t.Errorf("safeFileName(%q) = %q; want %q", tt.address, got, tt.want)
+		}
+	}
+}
+
+func TestAppointmentReminder(t *testing.T) {
+	transport := NewMemoryTransport()
+
+	m := New(transport, "Makerble <no-reply@makerble.test>", 0, 0)
+
+	err := m.Send("jane@example.com", "appointment_reminder.tmpl", map[string]any{
+		"name":          "Jane",
+		"patientName":   "John Smith",
+		"startsAt":      "Mon, 04 Mar 2024 09:00",
+		"endsAt":        "09:30",
+		"reason":        "follow-up",
+		"appointmentID": 42,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg := transport.Messages()[0]
+
+	if msg.Subject != "Upcoming appointment with John Smith" {
+		t.Errorf("Subject = %q", msg.Subject)
+	}
+
+	for _, body := range []string{msg.PlainBody, msg.HTMLBody} {
+		for _, want := range []string{"Hi Jane,", "Mon, 04 Mar 2024 09:00", "follow-up", "/v1/appointments/42"} {
+			if !strings.Contains(body, want) {
+				t.Errorf("body doesn't contain %q:\n%s", want, body)
+			}
+		}
+	}
+}
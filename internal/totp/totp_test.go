@@ -0,0 +1,96 @@
+package totp
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA-1 secret of the RFC 6238 test vectors.
+var rfcSecret = []byte("12345678901234567890")
+
+func TestCode(t *testing.T) {
+	// RFC 6238 appendix B lists eight-digit codes; six-digit codes are their
+	// last six digits.
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+
+	for _, tt := range tests {
+		got := Code(rfcSecret, Step(time.Unix(tt.unix, 0)))
+		if got != tt.want {
+			t.Errorf("Code at %d = %q; want %q", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	current := Step(now)
+
+	tests := []struct {
+		name     string
+		code     string
+		after    int64
+		wantStep int64
+		wantOK   bool
+	}{
+		{"current step", Code(rfcSecret, current), 0, current, true},
+		{"previous step", Code(rfcSecret, current-1), 0, current - 1, true},
+		{"next step", Code(rfcSecret, current+1), 0, current + 1, true},
+		{"two steps behind", Code(rfcSecret, current-2), 0, 0, false},
+		{"two steps ahead", Code(rfcSecret, current+2), 0, 0, false},
+		{"already used", Code(rfcSecret, current), current, 0, false},
+		{"earlier step after a later one was used", Code(rfcSecret, current-1), current, 0, false},
+		{"later step after an earlier one was used", Code(rfcSecret, current+1), current, current + 1, true},
+		{"wrong length", "12345", 0, 0, false},
+		{"wrong code", "000000", 0, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := Validate(rfcSecret, tt.code, now, tt.after)
+			if ok != tt.wantOK || step != tt.wantStep {
+				t.Errorf("Validate = (%d, %t); want (%d, %t)", step, ok, tt.wantStep, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestProvisioningURI(t *testing.T) {
+	uri := ProvisioningURI(rfcSecret, "Makerble", "jane@example.com")
+
+	u, err := url.Parse(uri)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if u.Scheme != "otpauth" || u.Host != "totp" {
+		t.Errorf("URI = %q; want an otpauth://totp/ URI", uri)
+	}
+
+	if !strings.HasSuffix(u.Path, "Makerble:jane@example.com") {
+		t.Errorf("label = %q; want it to end in Makerble:jane@example.com", u.Path)
+	}
+
+	q := u.Query()
+
+	if got := q.Get("secret"); got != EncodeSecret(rfcSecret) {
+		t.Errorf("secret = %q; want %q", got, EncodeSecret(rfcSecret))
+	}
+
+	for key, want := range map[string]string{"issuer": "Makerble", "digits": "6", "period": "30", "algorithm": "SHA1"} {
+		if got := q.Get(key); got != want {
+			t.Errorf("%s = %q; want %q", key, got, want)
+		}
+	}
+}
@@ -5,7 +5,6 @@ import (
 	"errors"
 	"regexp"
 	"slices"
-	"time"
 
 	"golang.org/x/crypto/bcrypt"
 )
@@ -98,7 +97,3 @@ func ValidatePlaintextPassword(v *Validator, plaintext string) {
 	v.Check(len(plaintext) >= 8, "password", "must be at least 8 bytes long")
 	v.Check(len(plaintext) <= 72, "password", "must be at most 72 bytes long")
 }
-
-func ValidateShift(v *Validator, shiftStart, shiftEnd time.Time) {
-	v.Check(shiftEnd.After(shiftStart), "shift", "shift timing should be valid")
-}
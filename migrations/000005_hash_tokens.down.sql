@@ -0,0 +1,3 @@
+DELETE FROM tokens;
+
+ALTER TABLE tokens ALTER COLUMN hash TYPE text USING encode(hash, 'hex');
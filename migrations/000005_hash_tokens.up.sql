@@ -0,0 +1,4 @@
+-- Existing rows hold plaintext tokens, so they are dropped rather than hashed in place.
+DELETE FROM tokens;
+
+ALTER TABLE tokens ALTER COLUMN hash TYPE bytea USING hash::bytea;
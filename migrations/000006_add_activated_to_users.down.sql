@@ -0,0 +1,3 @@
+ALTER TABLE doctors DROP COLUMN IF EXISTS activated;
+
+ALTER TABLE receptionists DROP COLUMN IF EXISTS activated;
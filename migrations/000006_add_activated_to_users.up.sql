@@ -0,0 +1,6 @@
+-- Accounts that already exist stay usable, only new registrations start inactive.
+ALTER TABLE doctors ADD COLUMN IF NOT EXISTS activated bool NOT NULL DEFAULT true;
+ALTER TABLE doctors ALTER COLUMN activated SET DEFAULT false;
+
+ALTER TABLE receptionists ADD COLUMN IF NOT EXISTS activated bool NOT NULL DEFAULT true;
+ALTER TABLE receptionists ALTER COLUMN activated SET DEFAULT false;
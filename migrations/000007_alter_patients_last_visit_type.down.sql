@@ -0,0 +1 @@
+ALTER TABLE patients ALTER COLUMN last_visit TYPE time USING last_visit::time;
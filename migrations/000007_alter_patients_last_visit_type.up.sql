@@ -0,0 +1,3 @@
+-- last_visit only stored the time of day, which makes date range filtering impossible.
+-- Existing values are assumed to belong to the day the migration runs.
+ALTER TABLE patients ALTER COLUMN last_visit TYPE timestamp(0) with time zone USING (CURRENT_DATE + last_visit);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS patients_contact_prefix_idx;
+DROP INDEX IF EXISTS patients_name_trgm_idx;
+DROP INDEX IF EXISTS patients_search_idx;
+
+ALTER TABLE patients DROP COLUMN IF EXISTS search;
@@ -0,0 +1,11 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+ALTER TABLE patients ADD COLUMN IF NOT EXISTS search tsvector GENERATED ALWAYS AS (
+  setweight(to_tsvector('english', name), 'A') ||
+  setweight(to_tsvector('english', address), 'B') ||
+  setweight(to_tsvector('english', medical_history), 'C')
+) STORED;
+
+CREATE INDEX IF NOT EXISTS patients_search_idx ON patients USING GIN (search);
+CREATE INDEX IF NOT EXISTS patients_name_trgm_idx ON patients USING GIN (name gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS patients_contact_prefix_idx ON patients ((contact::text) text_pattern_ops);
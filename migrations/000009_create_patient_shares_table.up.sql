@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS patient_shares (
+  patient_id bigint NOT NULL REFERENCES patients ON DELETE CASCADE,
+  doctor_id bigint NOT NULL REFERENCES doctors ON DELETE CASCADE,
+  created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+  PRIMARY KEY (patient_id, doctor_id)
+);
@@ -0,0 +1,9 @@
+DROP TABLE IF EXISTS role_permissions;
+
+DROP TABLE IF EXISTS permissions;
+
+DELETE FROM tokens WHERE role NOT IN ('doctor', 'receptionist');
+
+CREATE TYPE role_enum AS ENUM ('doctor', 'receptionist');
+
+ALTER TABLE tokens ALTER COLUMN role TYPE role_enum USING role::role_enum;
@@ -0,0 +1,36 @@
+-- Roles are plain text from now on so new ones only need rows in role_permissions.
+ALTER TABLE tokens ALTER COLUMN role TYPE text USING role::text;
+
+DROP TYPE IF EXISTS role_enum;
+
+CREATE TABLE IF NOT EXISTS permissions (
+  id bigserial PRIMARY KEY,
+  code text UNIQUE NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS role_permissions (
+  role text NOT NULL,
+  permission_id bigint NOT NULL REFERENCES permissions ON DELETE CASCADE,
+  PRIMARY KEY (role, permission_id)
+);
+
+-- Roles holding patients:all see every patient. Doctors without it only see
+-- the patients assigned to or shared with them, and any other role none.
+INSERT INTO permissions (code)
+VALUES
+  ('patients:read'),
+  ('patients:create'),
+  ('patients:write'),
+  ('patients:delete'),
+  ('patients:all'),
+  ('permissions:read'),
+  ('permissions:write');
+
+INSERT INTO role_permissions (role, permission_id)
+SELECT 'doctor', id FROM permissions WHERE code IN ('patients:read', 'patients:write');
+
+INSERT INTO role_permissions (role, permission_id)
+SELECT 'receptionist', id FROM permissions WHERE code IN ('patients:read', 'patients:create', 'patients:write', 'patients:delete', 'patients:all');
+
+INSERT INTO role_permissions (role, permission_id)
+SELECT 'admin', id FROM permissions;
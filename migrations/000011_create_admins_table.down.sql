@@ -0,0 +1,3 @@
+DELETE FROM permissions WHERE code IN ('users:read', 'users:write');
+
+DROP TABLE IF EXISTS admins;
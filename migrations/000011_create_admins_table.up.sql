@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS admins (
+  id bigserial PRIMARY KEY,
+  created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+  name text NOT NULL,
+  email citext UNIQUE NOT NULL,
+  password_hash bytea NOT NULL,
+  version integer NOT NULL DEFAULT 1,
+  activated bool NOT NULL DEFAULT false
+);
+
+INSERT INTO permissions (code)
+VALUES
+  ('users:read'),
+  ('users:write');
+
+INSERT INTO role_permissions (role, permission_id)
+SELECT 'admin', id FROM permissions WHERE code IN ('users:read', 'users:write');
@@ -0,0 +1,3 @@
+DELETE FROM permissions WHERE code IN ('appointments:read', 'appointments:write');
+
+DROP TABLE IF EXISTS appointments;
@@ -0,0 +1,34 @@
+CREATE EXTENSION IF NOT EXISTS btree_gist;
+
+CREATE TABLE IF NOT EXISTS appointments (
+  id bigserial PRIMARY KEY,
+  created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+  patient_id bigint NOT NULL REFERENCES patients ON DELETE CASCADE,
+  doctor_id bigint NOT NULL REFERENCES doctors ON DELETE CASCADE,
+  starts_at timestamp(0) with time zone NOT NULL,
+  ends_at timestamp(0) with time zone NOT NULL,
+  status text NOT NULL DEFAULT 'booked',
+  reason text NOT NULL DEFAULT '',
+  version integer NOT NULL DEFAULT 1,
+  CONSTRAINT appointments_time_check CHECK (ends_at > starts_at),
+  CONSTRAINT appointments_status_check CHECK (status IN ('booked', 'cancelled', 'completed', 'no_show')),
+  -- Only booked appointments hold their slot, cancelled ones free it up again.
+  CONSTRAINT appointments_no_overlap EXCLUDE USING gist (
+    doctor_id WITH =,
+    tstzrange(starts_at, ends_at) WITH &&
+  ) WHERE (status = 'booked')
+);
+
+CREATE INDEX IF NOT EXISTS appointments_patient_id_idx ON appointments (patient_id);
+
+INSERT INTO permissions (code)
+VALUES
+  ('appointments:read'),
+  ('appointments:write');
+
+INSERT INTO role_permissions (role, permission_id)
+SELECT 'doctor', id FROM permissions WHERE code = 'appointments:read';
+
+INSERT INTO role_permissions (role, permission_id)
+SELECT role, id FROM permissions, (VALUES ('receptionist'), ('admin')) AS roles (role)
+WHERE code IN ('appointments:read', 'appointments:write');
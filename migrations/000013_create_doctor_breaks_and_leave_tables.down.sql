@@ -0,0 +1,5 @@
+DELETE FROM permissions WHERE code IN ('schedules:read', 'schedules:write');
+
+DROP TABLE IF EXISTS doctor_leave;
+
+DROP TABLE IF EXISTS doctor_breaks;
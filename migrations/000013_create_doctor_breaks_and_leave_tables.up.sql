@@ -0,0 +1,36 @@
+CREATE TABLE IF NOT EXISTS doctor_breaks (
+  id bigserial PRIMARY KEY,
+  doctor_id bigint NOT NULL REFERENCES doctors ON DELETE CASCADE,
+  -- NULL repeats the break every day, otherwise 0 (Sunday) to 6 (Saturday).
+  weekday smallint CHECK (weekday BETWEEN 0 AND 6),
+  start_time time NOT NULL,
+  end_time time NOT NULL,
+  CONSTRAINT doctor_breaks_time_check CHECK (end_time > start_time)
+);
+
+CREATE INDEX IF NOT EXISTS doctor_breaks_doctor_id_idx ON doctor_breaks (doctor_id);
+
+CREATE TABLE IF NOT EXISTS doctor_leave (
+  id bigserial PRIMARY KEY,
+  created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+  doctor_id bigint NOT NULL REFERENCES doctors ON DELETE CASCADE,
+  starts_on date NOT NULL,
+  ends_on date NOT NULL,
+  reason text NOT NULL DEFAULT '',
+  CONSTRAINT doctor_leave_date_check CHECK (ends_on >= starts_on)
+);
+
+CREATE INDEX IF NOT EXISTS doctor_leave_doctor_id_idx ON doctor_leave (doctor_id);
+
+INSERT INTO permissions (code)
+VALUES
+  ('schedules:read'),
+  ('schedules:write');
+
+INSERT INTO role_permissions (role, permission_id)
+SELECT role, id FROM permissions, (VALUES ('doctor'), ('receptionist'), ('admin')) AS roles (role)
+WHERE code = 'schedules:read';
+
+INSERT INTO role_permissions (role, permission_id)
+SELECT role, id FROM permissions, (VALUES ('receptionist'), ('admin')) AS roles (role)
+WHERE code = 'schedules:write';
@@ -0,0 +1,28 @@
+-- Only a single daily shift fits the old columns, so the earliest segment of each member of staff wins.
+ALTER TABLE doctors ADD COLUMN IF NOT EXISTS shift_start time NOT NULL DEFAULT '09:00';
+ALTER TABLE doctors ADD COLUMN IF NOT EXISTS shift_end time NOT NULL DEFAULT '17:00';
+
+UPDATE doctors SET shift_start = s.start_time, shift_end = s.end_time
+FROM (
+  SELECT DISTINCT ON (staff_id) staff_id, start_time, end_time
+  FROM shifts WHERE staff_role = 'doctor'
+  ORDER BY staff_id, weekday, start_time
+) AS s
+WHERE doctors.id = s.staff_id;
+
+ALTER TABLE doctors ALTER COLUMN shift_start DROP DEFAULT, ALTER COLUMN shift_end DROP DEFAULT;
+
+ALTER TABLE receptionists ADD COLUMN IF NOT EXISTS shift_start time NOT NULL DEFAULT '09:00';
+ALTER TABLE receptionists ADD COLUMN IF NOT EXISTS shift_end time NOT NULL DEFAULT '17:00';
+
+UPDATE receptionists SET shift_start = s.start_time, shift_end = s.end_time
+FROM (
+  SELECT DISTINCT ON (staff_id) staff_id, start_time, end_time
+  FROM shifts WHERE staff_role = 'receptionist'
+  ORDER BY staff_id, weekday, start_time
+) AS s
+WHERE receptionists.id = s.staff_id;
+
+ALTER TABLE receptionists ALTER COLUMN shift_start DROP DEFAULT, ALTER COLUMN shift_end DROP DEFAULT;
+
+DROP TABLE IF EXISTS shifts;
@@ -0,0 +1,27 @@
+CREATE TABLE IF NOT EXISTS shifts (
+  id bigserial PRIMARY KEY,
+  staff_role text NOT NULL CHECK (staff_role IN ('doctor', 'receptionist')),
+  staff_id bigint NOT NULL,
+  -- 0 (Sunday) to 6 (Saturday), the day on which the shift starts.
+  weekday smallint NOT NULL CHECK (weekday BETWEEN 0 AND 6),
+  start_time time NOT NULL,
+  -- An end_time before start_time means the shift runs past midnight.
+  end_time time NOT NULL,
+  CONSTRAINT shifts_time_check CHECK (end_time <> start_time)
+);
+
+CREATE INDEX IF NOT EXISTS shifts_staff_idx ON shifts (staff_role, staff_id);
+
+INSERT INTO shifts (staff_role, staff_id, weekday, start_time, end_time)
+SELECT 'doctor', doctors.id, weekday, doctors.shift_start, doctors.shift_end
+FROM doctors, generate_series(0, 6) AS weekday
+WHERE doctors.shift_start <> doctors.shift_end;
+
+INSERT INTO shifts (staff_role, staff_id, weekday, start_time, end_time)
+SELECT 'receptionist', receptionists.id, weekday, receptionists.shift_start, receptionists.shift_end
+FROM receptionists, generate_series(0, 6) AS weekday
+WHERE receptionists.shift_start <> receptionists.shift_end;
+
+ALTER TABLE doctors DROP COLUMN IF EXISTS shift_start, DROP COLUMN IF EXISTS shift_end;
+
+ALTER TABLE receptionists DROP COLUMN IF EXISTS shift_start, DROP COLUMN IF EXISTS shift_end;
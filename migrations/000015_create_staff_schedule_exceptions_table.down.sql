@@ -0,0 +1,21 @@
+DELETE FROM permissions WHERE code IN ('leave:request', 'leave:approve');
+
+CREATE TABLE IF NOT EXISTS doctor_leave (
+  id bigserial PRIMARY KEY,
+  created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+  doctor_id bigint NOT NULL REFERENCES doctors ON DELETE CASCADE,
+  starts_on date NOT NULL,
+  ends_on date NOT NULL,
+  reason text NOT NULL DEFAULT '',
+  CONSTRAINT doctor_leave_date_check CHECK (ends_on >= starts_on)
+);
+
+CREATE INDEX IF NOT EXISTS doctor_leave_doctor_id_idx ON doctor_leave (doctor_id);
+
+INSERT INTO doctor_leave (created_at, doctor_id, starts_on, ends_on, reason)
+SELECT created_at, staff_id, starts_at::date, (ends_at - interval '1 second')::date, reason
+FROM staff_schedule_exceptions
+WHERE kind = 'leave' AND staff_role = 'doctor' AND status = 'approved'
+AND staff_id IN (SELECT id FROM doctors);
+
+DROP TABLE IF EXISTS staff_schedule_exceptions;
@@ -0,0 +1,47 @@
+CREATE TABLE IF NOT EXISTS staff_schedule_exceptions (
+  id bigserial PRIMARY KEY,
+  created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+  kind text NOT NULL,
+  -- Public holidays apply to everyone and have no staff member.
+  staff_role text,
+  staff_id bigint,
+  starts_at timestamp(0) with time zone NOT NULL,
+  ends_at timestamp(0) with time zone NOT NULL,
+  status text NOT NULL DEFAULT 'pending',
+  reason text NOT NULL DEFAULT '',
+  requested_by citext NOT NULL DEFAULT '',
+  reviewed_by citext,
+  reviewed_at timestamp(0) with time zone,
+  version integer NOT NULL DEFAULT 1,
+  CONSTRAINT staff_schedule_exceptions_kind_check CHECK (kind IN ('leave', 'holiday', 'on_call')),
+  CONSTRAINT staff_schedule_exceptions_status_check CHECK (status IN ('pending', 'approved', 'rejected')),
+  CONSTRAINT staff_schedule_exceptions_role_check CHECK (staff_role IN ('doctor', 'receptionist')),
+  CONSTRAINT staff_schedule_exceptions_staff_check CHECK (
+    (kind = 'holiday' AND staff_role IS NULL AND staff_id IS NULL)
+    OR (kind <> 'holiday' AND staff_role IS NOT NULL AND staff_id IS NOT NULL)
+  ),
+  CONSTRAINT staff_schedule_exceptions_time_check CHECK (ends_at > starts_at)
+);
+
+CREATE INDEX IF NOT EXISTS staff_schedule_exceptions_staff_idx ON staff_schedule_exceptions (staff_role, staff_id);
+CREATE INDEX IF NOT EXISTS staff_schedule_exceptions_time_idx ON staff_schedule_exceptions USING gist (tstzrange(starts_at, ends_at));
+
+-- Leave used to be whole days in the clinic's time zone, which is assumed to
+-- be the database's.
+INSERT INTO staff_schedule_exceptions (created_at, kind, staff_role, staff_id, starts_at, ends_at, status, reason)
+SELECT created_at, 'leave', 'doctor', doctor_id, starts_on::timestamptz, (ends_on + 1)::timestamptz, 'approved', reason
+FROM doctor_leave;
+
+DROP TABLE IF EXISTS doctor_leave;
+
+INSERT INTO permissions (code)
+VALUES
+  ('leave:request'),
+  ('leave:approve');
+
+INSERT INTO role_permissions (role, permission_id)
+SELECT role, id FROM permissions, (VALUES ('doctor'), ('receptionist'), ('admin')) AS roles (role)
+WHERE code = 'leave:request';
+
+INSERT INTO role_permissions (role, permission_id)
+SELECT 'admin', id FROM permissions WHERE code = 'leave:approve';
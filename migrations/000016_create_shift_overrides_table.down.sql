@@ -0,0 +1,5 @@
+DELETE FROM permissions WHERE code = 'shifts:override';
+
+DROP TABLE IF EXISTS shift_override_uses;
+
+DROP TABLE IF EXISTS shift_overrides;
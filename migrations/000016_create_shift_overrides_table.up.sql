@@ -0,0 +1,30 @@
+CREATE TABLE IF NOT EXISTS shift_overrides (
+  id bigserial PRIMARY KEY,
+  created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+  receptionist_id bigint NOT NULL REFERENCES receptionists ON DELETE CASCADE,
+  starts_at timestamp(0) with time zone NOT NULL,
+  ends_at timestamp(0) with time zone NOT NULL,
+  reason text NOT NULL,
+  -- Email of the admin who granted the override.
+  granted_by citext NOT NULL,
+  CONSTRAINT shift_overrides_time_check CHECK (ends_at > starts_at)
+);
+
+CREATE INDEX IF NOT EXISTS shift_overrides_receptionist_id_idx ON shift_overrides (receptionist_id);
+
+-- Every request a receptionist makes outside their shift under an override.
+CREATE TABLE IF NOT EXISTS shift_override_uses (
+  id bigserial PRIMARY KEY,
+  override_id bigint NOT NULL REFERENCES shift_overrides ON DELETE CASCADE,
+  used_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+  method text NOT NULL,
+  uri text NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS shift_override_uses_override_id_idx ON shift_override_uses (override_id);
+
+INSERT INTO permissions (code)
+VALUES ('shifts:override');
+
+INSERT INTO role_permissions (role, permission_id)
+SELECT 'admin', id FROM permissions WHERE code = 'shifts:override';
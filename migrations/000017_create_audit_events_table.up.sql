@@ -0,0 +1,38 @@
+CREATE TABLE IF NOT EXISTS audit_events (
+  id bigserial PRIMARY KEY,
+  -- Written by the API with microsecond precision, because it is part of the hash.
+  created_at timestamp(6) with time zone NOT NULL,
+  actor_email citext NOT NULL,
+  actor_role text NOT NULL,
+  action text NOT NULL,
+  -- No foreign key, the trail has to outlive the patient record.
+  patient_id bigint NOT NULL,
+  request_id text NOT NULL,
+  ip text NOT NULL,
+  -- json rather than jsonb keeps the bytes that were hashed.
+  changes json,
+  prev_hash bytea NOT NULL,
+  hash bytea NOT NULL UNIQUE
+);
+
+CREATE INDEX IF NOT EXISTS audit_events_patient_id_idx ON audit_events (patient_id);
+
+CREATE OR REPLACE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+  RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_events_no_change
+BEFORE UPDATE OR DELETE ON audit_events
+FOR EACH ROW EXECUTE FUNCTION audit_events_append_only();
+
+CREATE TRIGGER audit_events_no_truncate
+BEFORE TRUNCATE ON audit_events
+FOR EACH STATEMENT EXECUTE FUNCTION audit_events_append_only();
+
+INSERT INTO permissions (code)
+VALUES ('audit:read');
+
+INSERT INTO role_permissions (role, permission_id)
+SELECT 'admin', id FROM permissions WHERE code = 'audit:read';
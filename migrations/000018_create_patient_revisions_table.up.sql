@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS patient_revisions (
+  patient_id bigint NOT NULL REFERENCES patients ON DELETE CASCADE,
+  version integer NOT NULL,
+  revised_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+  name text NOT NULL,
+  gender text NOT NULL,
+  age float(10) NOT NULL,
+  contact integer NOT NULL,
+  address text NOT NULL,
+  medical_history text NOT NULL,
+  insurance_info text NOT NULL,
+  last_visit timestamp(0) with time zone NOT NULL,
+  doctor_id bigint NOT NULL,
+  PRIMARY KEY (patient_id, version)
+);
+
+-- Earlier versions are gone, so history starts from each patient's current
+-- version.
+INSERT INTO patient_revisions (patient_id, version, name, gender, age, contact, address, medical_history, insurance_info, last_visit, doctor_id)
+SELECT id, version, name, gender, age, contact, address, medical_history, insurance_info, last_visit, doctor_id
+FROM patients;
@@ -0,0 +1,7 @@
+DELETE FROM permissions WHERE code = 'patients:restore';
+
+DROP INDEX IF EXISTS patients_deleted_at_idx;
+
+ALTER TABLE patients DROP COLUMN IF EXISTS delete_reason;
+ALTER TABLE patients DROP COLUMN IF EXISTS deleted_by;
+ALTER TABLE patients DROP COLUMN IF EXISTS deleted_at;
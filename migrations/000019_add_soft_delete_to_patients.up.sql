@@ -0,0 +1,12 @@
+ALTER TABLE patients ADD COLUMN IF NOT EXISTS deleted_at timestamp(0) with time zone;
+ALTER TABLE patients ADD COLUMN IF NOT EXISTS deleted_by citext;
+ALTER TABLE patients ADD COLUMN IF NOT EXISTS delete_reason text;
+
+-- Only archived rows are looked up by deleted_at, by the purge job.
+CREATE INDEX IF NOT EXISTS patients_deleted_at_idx ON patients (deleted_at) WHERE deleted_at IS NOT NULL;
+
+INSERT INTO permissions (code)
+VALUES ('patients:restore');
+
+INSERT INTO role_permissions (role, permission_id)
+SELECT 'admin', id FROM permissions WHERE code = 'patients:restore';
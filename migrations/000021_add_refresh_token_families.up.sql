@@ -0,0 +1,8 @@
+-- Tokens handed out by one login share a family, so that reusing a rotated
+-- refresh token can revoke everything that login produced.
+ALTER TABLE tokens ADD COLUMN IF NOT EXISTS family text;
+-- Refresh tokens are marked as used on rotation rather than deleted, which is
+-- what lets a second use be told apart from an unknown token.
+ALTER TABLE tokens ADD COLUMN IF NOT EXISTS used_at timestamp(0) with time zone;
+
+CREATE INDEX IF NOT EXISTS tokens_family_idx ON tokens (family);
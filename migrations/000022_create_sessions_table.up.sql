@@ -0,0 +1,26 @@
+-- A session is one login: the token family started by it, and where it came from.
+CREATE TABLE IF NOT EXISTS sessions (
+  id bigserial PRIMARY KEY,
+  family text UNIQUE NOT NULL,
+  email citext NOT NULL,
+  role text NOT NULL,
+  user_agent text NOT NULL DEFAULT '',
+  ip text NOT NULL DEFAULT '',
+  created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+  last_used_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS sessions_email_role_idx ON sessions (email, role);
+
+-- Tokens issued before refresh tokens have no family yet, so each of them
+-- becomes a session of its own.
+UPDATE tokens SET family = encode(hash, 'hex')
+WHERE family IS NULL AND scope = 'authentication';
+
+INSERT INTO sessions (family, email, role)
+SELECT DISTINCT family, email, role
+FROM tokens
+WHERE family IS NOT NULL;
+
+ALTER TABLE tokens
+  ADD CONSTRAINT tokens_family_fkey FOREIGN KEY (family) REFERENCES sessions (family) ON DELETE CASCADE;
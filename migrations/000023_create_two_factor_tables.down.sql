@@ -0,0 +1,5 @@
+DELETE FROM permissions WHERE code = '2fa:manage';
+
+DROP TABLE IF EXISTS two_factor_roles;
+DROP TABLE IF EXISTS totp_recovery_codes;
+DROP TABLE IF EXISTS totp_credentials;
@@ -0,0 +1,35 @@
+-- Accounts live in one table per role, so like tokens they are keyed by email and role.
+CREATE TABLE IF NOT EXISTS totp_credentials (
+  email citext NOT NULL,
+  role text NOT NULL,
+  secret bytea NOT NULL,
+  created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+  -- NULL until the user proves their app works by entering a code.
+  confirmed_at timestamp(0) with time zone,
+  -- The last time step a code was accepted for, so codes can't be replayed.
+  last_used_step bigint NOT NULL DEFAULT 0,
+  PRIMARY KEY (email, role)
+);
+
+CREATE TABLE IF NOT EXISTS totp_recovery_codes (
+  id bigserial PRIMARY KEY,
+  email citext NOT NULL,
+  role text NOT NULL,
+  hash bytea NOT NULL,
+  used_at timestamp(0) with time zone,
+  FOREIGN KEY (email, role) REFERENCES totp_credentials ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS totp_recovery_codes_email_role_idx ON totp_recovery_codes (email, role);
+
+CREATE TABLE IF NOT EXISTS two_factor_roles (
+  role text PRIMARY KEY,
+  required_by citext NOT NULL,
+  created_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+INSERT INTO permissions (code)
+VALUES ('2fa:manage');
+
+INSERT INTO role_permissions (role, permission_id)
+SELECT 'admin', id FROM permissions WHERE code = '2fa:manage';
@@ -0,0 +1,4 @@
+DELETE FROM permissions WHERE code IN ('security:read', 'users:unlock');
+
+DROP TABLE IF EXISTS security_events;
+DROP TABLE IF EXISTS login_throttles;
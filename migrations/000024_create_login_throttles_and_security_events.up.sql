@@ -0,0 +1,31 @@
+-- Failed logins are counted per email and per client IP.
+CREATE TABLE IF NOT EXISTS login_throttles (
+  kind text NOT NULL CHECK (kind IN ('email', 'ip')),
+  subject citext NOT NULL,
+  failures integer NOT NULL DEFAULT 0,
+  last_failure_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+  locked_until timestamp(0) with time zone,
+  PRIMARY KEY (kind, subject)
+);
+
+CREATE TABLE IF NOT EXISTS security_events (
+  id bigserial PRIMARY KEY,
+  created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+  kind text NOT NULL,
+  email citext NOT NULL,
+  role text NOT NULL DEFAULT '',
+  ip text NOT NULL DEFAULT '',
+  user_agent text NOT NULL DEFAULT '',
+  details text NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS security_events_email_idx ON security_events (email);
+CREATE INDEX IF NOT EXISTS security_events_kind_idx ON security_events (kind);
+
+INSERT INTO permissions (code)
+VALUES
+  ('security:read'),
+  ('users:unlock');
+
+INSERT INTO role_permissions (role, permission_id)
+SELECT 'admin', id FROM permissions WHERE code IN ('security:read', 'users:unlock');
@@ -0,0 +1 @@
+DELETE FROM permissions WHERE code IN ('patients:own', 'appointments:all', 'appointments:own');
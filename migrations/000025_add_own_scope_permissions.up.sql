@@ -0,0 +1,15 @@
+-- Roles holding patients:own or appointments:own are limited to the patients
+-- and appointments assigned to the user, which only means something for
+-- doctors. appointments:all sees every appointment, like patients:all.
+INSERT INTO permissions (code)
+VALUES
+  ('patients:own'),
+  ('appointments:all'),
+  ('appointments:own');
+
+INSERT INTO role_permissions (role, permission_id)
+SELECT 'doctor', id FROM permissions WHERE code IN ('patients:own', 'appointments:own');
+
+INSERT INTO role_permissions (role, permission_id)
+SELECT role, id FROM permissions, (VALUES ('receptionist'), ('admin')) AS roles (role)
+WHERE code = 'appointments:all';
@@ -0,0 +1 @@
+ALTER TABLE patients DROP CONSTRAINT IF EXISTS patients_doctor_id_fkey;
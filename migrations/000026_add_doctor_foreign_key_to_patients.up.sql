@@ -0,0 +1,6 @@
+-- NOT VALID leaves patients already assigned to a missing doctor alone, but
+-- every insert and update from now on has to name an existing doctor. Run
+-- ALTER TABLE patients VALIDATE CONSTRAINT patients_doctor_id_fkey once they
+-- have been reassigned.
+ALTER TABLE patients
+  ADD CONSTRAINT patients_doctor_id_fkey FOREIGN KEY (doctor_id) REFERENCES doctors NOT VALID;
@@ -0,0 +1,6 @@
+DELETE FROM doctor_breaks WHERE end_time < start_time;
+
+ALTER TABLE doctor_breaks DROP CONSTRAINT IF EXISTS doctor_breaks_time_check;
+
+ALTER TABLE doctor_breaks
+  ADD CONSTRAINT doctor_breaks_time_check CHECK (end_time > start_time);
@@ -0,0 +1,5 @@
+-- Breaks may run past midnight during overnight shifts, ending the next day.
+ALTER TABLE doctor_breaks DROP CONSTRAINT IF EXISTS doctor_breaks_time_check;
+
+ALTER TABLE doctor_breaks
+  ADD CONSTRAINT doctor_breaks_time_check CHECK (end_time <> start_time);